@@ -0,0 +1,252 @@
+// Package bls12381 allows to use Handel with the BLS signature scheme over the
+// BLS12-381 curve. It implements the relevant Handel interfaces: PublicKey,
+// Secretkey and Signature. It follows the conventions of the Ethereum 2.0
+// and Filecoin ecosystems: public keys are points on G1, signatures are points
+// on G2, both compressed when marshalled, and messages are hashed to G2 as
+// specified by the BLS signature draft of the IETF, using the DST of the proof
+// of possession scheme. The BLS12-381 implementation comes from the
+// supranational/blst package.
+package bls12381
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"github.com/ConsenSys/handel"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// DST is the domain separation tag used to hash the messages to G2. If one
+// wants to use a different tag, set this variable before using any public
+// methods / structs of this package.
+var DST = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+
+// Constructor implements the handel.Constructor interface
+type Constructor struct {
+}
+
+// NewConstructor returns a handel.Constructor capable of creating empty BLS
+// signature object and empty public keys.
+func NewConstructor() *Constructor {
+	return &Constructor{}
+}
+
+// Signature implements the handel.Constructor  interface
+func (s *Constructor) Signature() handel.Signature {
+	return new(SigBLS)
+}
+
+// PublicKey implements the handel.Constructor interface
+func (s *Constructor) PublicKey() handel.PublicKey {
+	return new(PublicKey)
+}
+
+// SecretKey implements the simul/lib/Constructor interface
+func (s *Constructor) SecretKey() handel.SecretKey {
+	return new(SecretKey)
+}
+
+// KeyPair implements the simul/lib/Constructor interface
+func (s *Constructor) KeyPair(r io.Reader) (handel.SecretKey, handel.PublicKey) {
+	secret, pub, err := NewKeyPair(r)
+	if err != nil {
+		// this method is only used in simulation code anyway
+		panic(err)
+	}
+	return secret, pub
+}
+
+// VerifyBatch implements the handel.BatchVerifier interface. It checks all the
+// signatures at once, each one being weighted by a random 64-bit scalar. An
+// invalid signature makes the verification fail, except with negligible
+// probability.
+func (s *Constructor) VerifyBatch(msg []byte, pubs []handel.PublicKey, sigs []handel.Signature) error {
+	if len(pubs) != len(sigs) {
+		return errors.New("bls12381: inconsistent batch")
+	}
+	if len(pubs) == 0 {
+		return nil
+	}
+	affinePubs := make([]*blst.P1Affine, len(pubs))
+	affineSigs := make([]*blst.P2Affine, len(sigs))
+	msgs := make([]blst.Message, len(pubs))
+	for i := range pubs {
+		p := pubs[i].(*PublicKey)
+		sig := sigs[i].(*SigBLS)
+		if p.p == nil || sig.e == nil {
+			return errors.New("bls12381: empty key or signature in batch")
+		}
+		affinePubs[i] = p.p.ToAffine()
+		affineSigs[i] = sig.e.ToAffine()
+		msgs[i] = msg
+	}
+	randFn := func(scalar *blst.Scalar) {
+		var buff [32]byte
+		rand.Read(buff[:])
+		scalar.FromLEndian(buff[:])
+	}
+	if !new(blst.P2Affine).MultipleAggregateVerify(affineSigs, true, affinePubs, false, msgs, DST, randFn, 64) {
+		return errors.New("bls12381: invalid signature in batch")
+	}
+	return nil
+}
+
+// PublicKey holds the public key information = point in G1
+type PublicKey struct {
+	p *blst.P1
+}
+
+func (p *PublicKey) String() string {
+	buff, _ := p.MarshalBinary()
+	s := sha256.Sum256(buff)
+	return hex.EncodeToString(s[:])
+}
+
+// VerifySignature checks the given BLS signature on the message using the
+// public key p by verifying that the equality e(X, H(m)) == e(G1, S) holds,
+// where e is the pairing operation, G1 the generator of the group G1 and H the
+// hash to G2.
+func (p *PublicKey) VerifySignature(msg []byte, sig handel.Signature) error {
+	ms := sig.(*SigBLS)
+	if p.p == nil || ms.e == nil {
+		return errors.New("bls12381: empty key or signature")
+	}
+	if !ms.e.ToAffine().Verify(true, p.p.ToAffine(), false, msg, DST) {
+		return errors.New("bls12381: signature invalid")
+	}
+	return nil
+}
+
+// Combine implements the handel.PublicKey interface
+func (p *PublicKey) Combine(pp handel.PublicKey) handel.PublicKey {
+	if p.p == nil {
+		return pp
+	}
+	p2 := pp.(*PublicKey)
+	return &PublicKey{p.p.Add(p2.p)}
+}
+
+// Subtract implements the handel.SubtractablePublicKey interface
+func (p *PublicKey) Subtract(pp handel.PublicKey) handel.PublicKey {
+	p2 := pp.(*PublicKey)
+	if p2.p == nil {
+		return p
+	}
+	if p.p == nil {
+		return &PublicKey{new(blst.P1).Sub(p2.p)}
+	}
+	return &PublicKey{p.p.Sub(p2.p)}
+}
+
+// MarshalBinary implements the simul/lib/PublicKey interface
+func (p *PublicKey) MarshalBinary() ([]byte, error) {
+	if p.p == nil {
+		return nil, errors.New("bls12381: public key can't marshal if nil")
+	}
+	return p.p.Compress(), nil
+}
+
+// UnmarshalBinary implements the simul/lib/PublicKey interface. The public
+// key must be a point of G1 other than the point at infinity.
+func (p *PublicKey) UnmarshalBinary(buff []byte) error {
+	affine := new(blst.P1Affine).Uncompress(buff)
+	if affine == nil || !affine.KeyValidate() {
+		return errors.New("bls12381: invalid public key")
+	}
+	p.p = new(blst.P1)
+	p.p.FromAffine(affine)
+	return nil
+}
+
+// SecretKey holds the secret scalar and can return the corresponding public
+// key. It can sign messages using the BLS signature scheme.
+type SecretKey struct {
+	s *blst.SecretKey
+}
+
+// NewKeyPair returns a new keypair generated from the given reader.
+func NewKeyPair(reader io.Reader) (*SecretKey, *PublicKey, error) {
+	if reader == nil {
+		reader = rand.Reader
+	}
+	var ikm [32]byte
+	if _, err := io.ReadFull(reader, ikm[:]); err != nil {
+		return nil, nil, err
+	}
+	secret := blst.KeyGen(ikm[:])
+	public := new(blst.P1)
+	public.FromAffine(new(blst.P1Affine).From(secret))
+	return &SecretKey{
+			s: secret,
+		}, &PublicKey{
+			p: public,
+		}, nil
+}
+
+// Sign creates a BLS signature S = x * H(m) on a message m using the private
+// key x. The signature S is a point on curve G2.
+func (s *SecretKey) Sign(msg []byte, reader io.Reader) (handel.Signature, error) {
+	sig := new(blst.P2Affine).Sign(s.s, msg, DST)
+	if sig == nil {
+		return nil, errors.New("bls12381: can't sign")
+	}
+	e := new(blst.P2)
+	e.FromAffine(sig)
+	return &SigBLS{e}, nil
+}
+
+// MarshalBinary implements the simul/lib/SecretKey interface
+func (s *SecretKey) MarshalBinary() ([]byte, error) {
+	return s.s.Serialize(), nil
+}
+
+// UnmarshalBinary implements the simul/lib/SecretKey interface
+func (s *SecretKey) UnmarshalBinary(buff []byte) error {
+	s.s = new(blst.SecretKey).Deserialize(buff)
+	if s.s == nil || !s.s.Valid() {
+		return errors.New("bls12381: invalid secret key")
+	}
+	return nil
+}
+
+// SigBLS represents a BLS signature using the BLS12-381 curve
+type SigBLS struct {
+	e *blst.P2
+}
+
+// MarshalBinary implements the handel.Signature interface
+func (m *SigBLS) MarshalBinary() ([]byte, error) {
+	if m.e == nil {
+		return nil, errors.New("bls12381: multisig can't marshal if nil")
+	}
+	return m.e.Compress(), nil
+}
+
+// UnmarshalBinary implements the handel.Signature interface. The subgroup
+// check of the signature is done when it is verified.
+func (m *SigBLS) UnmarshalBinary(b []byte) error {
+	affine := new(blst.P2Affine).Uncompress(b)
+	if affine == nil {
+		return errors.New("bls12381: multisig can't unmarshal")
+	}
+	m.e = new(blst.P2)
+	m.e.FromAffine(affine)
+	return nil
+}
+
+// Combine implements the handel.Signature interface
+func (m *SigBLS) Combine(ms handel.Signature) handel.Signature {
+	if m.e == nil {
+		return ms
+	}
+	m2 := ms.(*SigBLS)
+	return &SigBLS{e: m.e.Add(m2.e)}
+}
+
+func (m *SigBLS) String() string {
+	buff, _ := m.MarshalBinary()
+	return hex.EncodeToString(buff)
+}
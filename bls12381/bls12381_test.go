@@ -0,0 +1,180 @@
+package bls12381
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	h "github.com/ConsenSys/handel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandel(t *testing.T) {
+	n := 37
+	config := h.DefaultConfig(n)
+	msg := []byte("Peaches and Cream")
+	secretKeys := make([]h.SecretKey, n)
+	pubKeys := make([]h.PublicKey, n)
+	cons := NewConstructor()
+	for i := 0; i < n; i++ {
+		sec, pub, err := NewKeyPair(nil)
+		require.NoError(t, err)
+		secretKeys[i] = sec
+		pubKeys[i] = pub
+	}
+	test := h.NewTest(secretKeys, pubKeys, cons, msg, config)
+	//test.SetOfflineNodes(15, 25, 8)
+	//test.SetThreshold(n - 4)
+	test.Start()
+	defer test.Stop()
+
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(100 * time.Second):
+		t.FailNow()
+	}
+}
+
+func TestSign(t *testing.T) {
+	reader := rand.Reader
+	msg := []byte("Get Funky Tonight")
+
+	sk, pk, err := NewKeyPair(reader)
+	require.NoError(t, err)
+
+	sig, err := sk.Sign(msg, nil)
+	require.NoError(t, err)
+	buff, _ := sig.MarshalBinary()
+	fmt.Println(len(buff))
+	err = pk.VerifySignature(msg, sig)
+	require.NoError(t, err)
+}
+
+func TestCombine(t *testing.T) {
+	reader := rand.Reader
+	msg := []byte("Get Funky Tonight")
+
+	sk1, pk1, err := NewKeyPair(reader)
+	require.NoError(t, err)
+
+	sk2, pk2, err := NewKeyPair(reader)
+	require.NoError(t, err)
+
+	require.NotEqual(t, pk1.String(), pk2.String())
+
+	sig1, err := sk1.Sign(msg, nil)
+	require.NoError(t, err)
+	require.NoError(t, pk1.VerifySignature(msg, sig1))
+
+	sig2, err := sk2.Sign(msg, nil)
+	require.NoError(t, err)
+	require.NoError(t, pk2.VerifySignature(msg, sig2))
+
+	sig3 := sig1.Combine(sig2)
+	pk3 := pk1.Combine(pk2)
+	require.NoError(t, pk3.VerifySignature(msg, sig3))
+}
+
+func TestSubtract(t *testing.T) {
+	msg := []byte("Get Funky Tonight")
+	var sks []*SecretKey
+	var pks []*PublicKey
+	for i := 0; i < 3; i++ {
+		sk, pk, err := NewKeyPair(rand.Reader)
+		require.NoError(t, err)
+		sks = append(sks, sk)
+		pks = append(pks, pk)
+	}
+	sig1, err := sks[0].Sign(msg, nil)
+	require.NoError(t, err)
+	sig2, err := sks[1].Sign(msg, nil)
+	require.NoError(t, err)
+	sig12 := sig1.Combine(sig2)
+
+	marshal := func(p h.PublicKey) []byte {
+		buff, err := p.(*PublicKey).MarshalBinary()
+		require.NoError(t, err)
+		return buff
+	}
+	full := pks[0].Combine(pks[1]).Combine(pks[2]).(*PublicKey)
+	pk12 := full.Subtract(pks[2])
+	require.NoError(t, pk12.VerifySignature(msg, sig12))
+	require.Equal(t, marshal(pks[0].Combine(pks[1])), marshal(pk12))
+	require.Error(t, full.Subtract(pks[1]).VerifySignature(msg, sig12))
+
+	// subtracting the empty key changes nothing, subtracting from the empty
+	// key gives the opposite
+	empty := new(Constructor).PublicKey()
+	require.Equal(t, marshal(full), marshal(full.Subtract(empty)))
+	require.Equal(t, marshal(pks[0]), marshal(empty.(*PublicKey).Subtract(pks[1]).Combine(pks[0]).Combine(pks[1])))
+}
+
+func TestVerifyBatch(t *testing.T) {
+	msg := []byte("Get Funky Tonight")
+	cons := NewConstructor()
+	n := 5
+	pubs := make([]h.PublicKey, n)
+	sigs := make([]h.Signature, n)
+	for i := 0; i < n; i++ {
+		sk, pk, err := NewKeyPair(rand.Reader)
+		require.NoError(t, err)
+		sig, err := sk.Sign(msg, nil)
+		require.NoError(t, err)
+		pubs[i] = pk
+		sigs[i] = sig
+	}
+	require.NoError(t, cons.VerifyBatch(msg, nil, nil))
+	require.NoError(t, cons.VerifyBatch(msg, pubs, sigs))
+	require.Error(t, cons.VerifyBatch(msg, pubs, sigs[1:]))
+	require.Error(t, cons.VerifyBatch([]byte("Get Funky Tomorrow"), pubs, sigs))
+
+	// swapped signatures are each invalid
+	sigs[1], sigs[2] = sigs[2], sigs[1]
+	require.Error(t, cons.VerifyBatch(msg, pubs, sigs))
+	sigs[1], sigs[2] = sigs[2], sigs[1]
+
+	// a signature from another key invalidates the batch
+	sk, _, err := NewKeyPair(rand.Reader)
+	require.NoError(t, err)
+	sigs[3], err = sk.Sign(msg, nil)
+	require.NoError(t, err)
+	require.Error(t, cons.VerifyBatch(msg, pubs, sigs))
+}
+
+func TestMarshalling(t *testing.T) {
+
+	sk, pk, err := NewKeyPair(nil)
+	require.NoError(t, err)
+
+	buffSK, err := sk.MarshalBinary()
+	require.NoError(t, err)
+
+	buffPK, err := pk.MarshalBinary()
+	require.NoError(t, err)
+
+	cons := NewConstructor()
+
+	sk2 := cons.SecretKey()
+	err = sk2.(*SecretKey).UnmarshalBinary(buffSK)
+	require.NoError(t, err)
+
+	pk2 := cons.PublicKey()
+	err = pk2.(*PublicKey).UnmarshalBinary(buffPK)
+	require.NoError(t, err)
+
+	msg := []byte("Get Funky Tonight")
+	sig, err := sk2.Sign(msg, nil)
+	require.NoError(t, err)
+	buffSig, err := sig.MarshalBinary()
+	require.NoError(t, err)
+	sig2 := cons.Signature()
+	require.NoError(t, sig2.UnmarshalBinary(buffSig))
+	require.NoError(t, pk2.VerifySignature(msg, sig2))
+
+	// points not on the curve are rejected
+	buffPK[len(buffPK)-1]++
+	require.Error(t, new(PublicKey).UnmarshalBinary(buffPK))
+	require.Error(t, new(PublicKey).UnmarshalBinary(buffPK[1:]))
+	require.Error(t, new(SigBLS).UnmarshalBinary(buffSig[1:]))
+}
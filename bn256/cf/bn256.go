@@ -14,12 +14,19 @@ import (
 	"math/big"
 
 	"github.com/ConsenSys/handel"
+	"github.com/ConsenSys/handel/bn256/internal/hashg1"
 	"github.com/cloudflare/bn256"
 )
 
-// Hash is the hash function used to hash the message prior to signing
+// Hash is the hash function used to expand the message before mapping it to
+// G1.
 var Hash = sha256.New
 
+// DST is the domain separation tag used to hash the messages to G1. If one
+// wants to use a different tag, set this variable before using any public
+// methods / structs of this package.
+var DST = []byte("HANDEL_BLS_SIG_BN256G1_XMD:SHA-256_SVDW_RO_")
+
 // G2Base is the base point specified for the G2 group. If one wants to use a
 // different point, set this variable before using any public methods / structs
 // of this package.
@@ -67,6 +74,51 @@ func (s *Constructor) KeyPair(r io.Reader) (handel.SecretKey, handel.PublicKey)
 	return secret, pub
 }
 
+// VerifyBatch implements the handel.BatchVerifier interface. It checks all the
+// signatures at once by verifying that the equality e(sum r_i*S_i, B2) ==
+// e(H(m), sum r_i*X_i) holds, where the r_i are random 128-bit scalars. An
+// invalid signature makes the equality fail, except with negligible
+// probability.
+func (s *Constructor) VerifyBatch(msg []byte, pubs []handel.PublicKey, sigs []handel.Signature) error {
+	if len(pubs) != len(sigs) {
+		return errors.New("bn256: inconsistent batch")
+	}
+	if len(pubs) == 0 {
+		return nil
+	}
+	HM, err := hashedMessage(msg)
+	if err != nil {
+		return err
+	}
+	var aggSig *bn256.G1
+	var aggPub *bn256.G2
+	for i := range pubs {
+		r, err := rand.Int(rand.Reader, batchScalarMax)
+		if err != nil {
+			return err
+		}
+		r.Add(r, big.NewInt(1))
+		sig := new(bn256.G1).ScalarMult(sigs[i].(*SigBLS).e, r)
+		pub := new(bn256.G2).ScalarMult(pubs[i].(*PublicKey).p, r)
+		if aggSig == nil {
+			aggSig, aggPub = sig, pub
+			continue
+		}
+		aggSig.Add(aggSig, sig)
+		aggPub.Add(aggPub, pub)
+	}
+	leftPair := bn256.Pair(HM, aggPub).Marshal()
+	rightPair := bn256.Pair(aggSig, G2Base).Marshal()
+	if !bytes.Equal(leftPair, rightPair) {
+		return errors.New("bn256: invalid signature in batch")
+	}
+	return nil
+}
+
+// batchScalarMax is the upper bound of the random scalars used in batch
+// verification.
+var batchScalarMax = new(big.Int).Lsh(big.NewInt(1), 128)
+
 // PublicKey holds the public key information = point in G2
 type PublicKey struct {
 	p *bn256.G2
@@ -108,6 +160,21 @@ func (p *PublicKey) Combine(pp handel.PublicKey) handel.PublicKey {
 	return &PublicKey{p3}
 }
 
+// Subtract implements the handel.SubtractablePublicKey interface
+func (p *PublicKey) Subtract(pp handel.PublicKey) handel.PublicKey {
+	p2 := pp.(*PublicKey)
+	if p2.p == nil {
+		return p
+	}
+	neg := new(bn256.G2).Neg(p2.p)
+	if p.p == nil {
+		return &PublicKey{neg}
+	}
+	p3 := new(bn256.G2)
+	p3.Add(p.p, neg)
+	return &PublicKey{p3}
+}
+
 // MarshalBinary implements the simul/lib/PublicKey interface
 func (p *PublicKey) MarshalBinary() ([]byte, error) {
 	return p.p.Marshal(), nil
@@ -204,16 +271,16 @@ func (m *SigBLS) String() string {
 	return m.e.String()
 }
 
-// hashedMessage returns the message hashed to G1
-// XXX: this should be fixed as to have a method that maps a message
-// (potentially a digest) to a point WITHOUT knowing the corresponding scalar.
-// see issue: https://github.com/ConsenSys/handel/issues/122
+// hashedMessage returns the message hashed to G1 with the domain separation
+// tag DST.
 func hashedMessage(msg []byte) (*bn256.G1, error) {
-	h := Hash()
-	h.Write(msg)
-	hashed := h.Sum(nil)
-	reader := bytes.NewBuffer(hashed)
-	_, HM, err := bn256.RandomG1(reader)
-	return HM, err
-
+	buff, err := hashg1.Hash(msg, DST, Hash)
+	if err != nil {
+		return nil, err
+	}
+	HM := new(bn256.G1)
+	if _, err := HM.Unmarshal(buff); err != nil {
+		return nil, errors.New("bn256: invalid hashed message")
+	}
+	return HM, nil
 }
@@ -13,6 +13,7 @@ import (
 	"math/big"
 
 	"github.com/ConsenSys/handel"
+	"github.com/ConsenSys/handel/bn256/internal/hashg1"
 	//"github.com/cloudflare/bn256"
 	"golang.org/x/crypto/bn256"
 )
@@ -21,10 +22,15 @@ import (
 // ScalarMultBase(1)
 var G2Base *bn256.G2
 
-// Hash is the hash function used to digest a message before mapping it to a
-// point.
+// Hash is the hash function used to expand the message before mapping it to
+// G1.
 var Hash = sha256.New
 
+// DST is the domain separation tag used to hash the messages to G1. If one
+// wants to use a different tag, set this variable before using any public
+// methods / structs of this package.
+var DST = []byte("HANDEL_BLS_SIG_BN256G1_XMD:SHA-256_SVDW_RO_")
+
 func init() {
 	G2Base = new(bn256.G2)
 	exp := big.NewInt(1)
@@ -66,6 +72,51 @@ func (s *Constructor) KeyPair(r io.Reader) (handel.SecretKey, handel.PublicKey)
 	return secret, pub
 }
 
+// VerifyBatch implements the handel.BatchVerifier interface. It checks all the
+// signatures at once by verifying that the equality e(sum r_i*S_i, B2) ==
+// e(H(m), sum r_i*X_i) holds, where the r_i are random 128-bit scalars. An
+// invalid signature makes the equality fail, except with negligible
+// probability.
+func (s *Constructor) VerifyBatch(msg []byte, pubs []handel.PublicKey, sigs []handel.Signature) error {
+	if len(pubs) != len(sigs) {
+		return errors.New("bn256: inconsistent batch")
+	}
+	if len(pubs) == 0 {
+		return nil
+	}
+	HM, err := hashedMessage(msg)
+	if err != nil {
+		return err
+	}
+	var aggSig *bn256.G1
+	var aggPub *bn256.G2
+	for i := range pubs {
+		r, err := rand.Int(rand.Reader, batchScalarMax)
+		if err != nil {
+			return err
+		}
+		r.Add(r, big.NewInt(1))
+		sig := new(bn256.G1).ScalarMult(sigs[i].(*SigBLS).e, r)
+		pub := new(bn256.G2).ScalarMult(pubs[i].(*PublicKey).p, r)
+		if aggSig == nil {
+			aggSig, aggPub = sig, pub
+			continue
+		}
+		aggSig.Add(aggSig, sig)
+		aggPub.Add(aggPub, pub)
+	}
+	leftPair := bn256.Pair(HM, aggPub).Marshal()
+	rightPair := bn256.Pair(aggSig, G2Base).Marshal()
+	if !bytes.Equal(leftPair, rightPair) {
+		return errors.New("bn256: invalid signature in batch")
+	}
+	return nil
+}
+
+// batchScalarMax is the upper bound of the random scalars used in batch
+// verification.
+var batchScalarMax = new(big.Int).Lsh(big.NewInt(1), 128)
+
 // PublicKey holds the public key information = point in G2
 type PublicKey struct {
 	p *bn256.G2
@@ -104,6 +155,52 @@ func (p *PublicKey) Combine(pp handel.PublicKey) handel.PublicKey {
 	return &PublicKey{p3}
 }
 
+// Subtract implements the handel.SubtractablePublicKey interface
+func (p *PublicKey) Subtract(pp handel.PublicKey) handel.PublicKey {
+	p2 := pp.(*PublicKey)
+	if p2.p == nil {
+		return p
+	}
+	neg := negG2(p2.p)
+	if p.p == nil {
+		return &PublicKey{neg}
+	}
+	p3 := new(bn256.G2)
+	p3.Add(p.p, neg)
+	return &PublicKey{p3}
+}
+
+// fieldPrime is the prime of the base field of the curve, it is not exported
+// by golang.org/x/crypto/bn256.
+var fieldPrime, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+// g2Infinity is the point at infinity of G2.
+var g2Infinity, _ = new(bn256.G2).Unmarshal(make([]byte, 128))
+
+// negG2 returns the opposite of the given point. golang.org/x/crypto/bn256
+// does not provide it: the opposite of (x, y) being (x, -y), the y coordinate
+// of the marshalled point is negated.
+func negG2(a *bn256.G2) *bn256.G2 {
+	// Marshal normalizes the point in place, so a copy is marshalled since the
+	// public key may be used concurrently.
+	buff := new(bn256.G2).Add(a, g2Infinity).Marshal()
+	for i := 64; i < 128; i += 32 {
+		b := buff[i : i+32]
+		y := new(big.Int).SetBytes(b)
+		if y.Sign() == 0 {
+			continue
+		}
+		yb := y.Sub(fieldPrime, y).Bytes()
+		copy(b, make([]byte, 32-len(yb)))
+		copy(b[32-len(yb):], yb)
+	}
+	neg, ok := new(bn256.G2).Unmarshal(buff)
+	if !ok {
+		panic("bn256: invalid negated point")
+	}
+	return neg
+}
+
 // MarshalBinary implements the simul/lib/PublicKey interface
 func (p *PublicKey) MarshalBinary() ([]byte, error) {
 	return p.p.Marshal(), nil
@@ -203,16 +300,16 @@ func (m *SigBLS) String() string {
 	return m.e.String()
 }
 
-// hashedMessage returns the message hashed to G1
-// XXX: this should be fixed as to have a method that maps a message
-// (potentially a digest) to a point WITHOUT knowing the corresponding scalar.
-// see issue https://github.com/ConsenSys/handel/issues/122
+// hashedMessage returns the message hashed to G1 with the domain separation
+// tag DST.
 func hashedMessage(msg []byte) (*bn256.G1, error) {
-	h := Hash()
-	h.Write(msg)
-	hashed := h.Sum(nil)
-	reader := bytes.NewBuffer(hashed)
-	_, HM, err := bn256.RandomG1(reader)
-	return HM, err
-
+	buff, err := hashg1.Hash(msg, DST, Hash)
+	if err != nil {
+		return nil, err
+	}
+	HM := new(bn256.G1)
+	if _, ok := HM.Unmarshal(buff); !ok {
+		return nil, errors.New("bn256: invalid hashed message")
+	}
+	return HM, nil
 }
@@ -2,6 +2,7 @@ package bn256
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"testing"
 	"time"
@@ -80,6 +81,72 @@ func TestCombine(t *testing.T) {
 	require.NoError(t, pk3.VerifySignature(msg, sig3))
 }
 
+func TestSubtract(t *testing.T) {
+	msg := []byte("Get Funky Tonight")
+	var sks []*SecretKey
+	var pks []*PublicKey
+	for i := 0; i < 3; i++ {
+		sk, pk, err := NewKeyPair(rand.Reader)
+		require.NoError(t, err)
+		sks = append(sks, sk)
+		pks = append(pks, pk)
+	}
+	sig1, err := sks[0].Sign(msg, nil)
+	require.NoError(t, err)
+	sig2, err := sks[1].Sign(msg, nil)
+	require.NoError(t, err)
+	sig12 := sig1.Combine(sig2)
+
+	marshal := func(p h.PublicKey) []byte {
+		buff, err := p.(*PublicKey).MarshalBinary()
+		require.NoError(t, err)
+		return buff
+	}
+	full := pks[0].Combine(pks[1]).Combine(pks[2]).(*PublicKey)
+	pk12 := full.Subtract(pks[2])
+	require.NoError(t, pk12.VerifySignature(msg, sig12))
+	require.Equal(t, marshal(pks[0].Combine(pks[1])), marshal(pk12))
+	require.Error(t, full.Subtract(pks[1]).VerifySignature(msg, sig12))
+
+	// subtracting the empty key changes nothing, subtracting from the empty
+	// key gives the opposite
+	empty := new(Constructor).PublicKey()
+	require.Equal(t, marshal(full), marshal(full.Subtract(empty)))
+	require.Equal(t, marshal(pks[0]), marshal(empty.(*PublicKey).Subtract(pks[1]).Combine(pks[0]).Combine(pks[1])))
+}
+
+func TestVerifyBatch(t *testing.T) {
+	msg := []byte("Get Funky Tonight")
+	cons := NewConstructor()
+	n := 5
+	pubs := make([]h.PublicKey, n)
+	sigs := make([]h.Signature, n)
+	for i := 0; i < n; i++ {
+		sk, pk, err := NewKeyPair(rand.Reader)
+		require.NoError(t, err)
+		sig, err := sk.Sign(msg, nil)
+		require.NoError(t, err)
+		pubs[i] = pk
+		sigs[i] = sig
+	}
+	require.NoError(t, cons.VerifyBatch(msg, nil, nil))
+	require.NoError(t, cons.VerifyBatch(msg, pubs, sigs))
+	require.Error(t, cons.VerifyBatch(msg, pubs, sigs[1:]))
+	require.Error(t, cons.VerifyBatch([]byte("Get Funky Tomorrow"), pubs, sigs))
+
+	// swapped signatures are each invalid
+	sigs[1], sigs[2] = sigs[2], sigs[1]
+	require.Error(t, cons.VerifyBatch(msg, pubs, sigs))
+	sigs[1], sigs[2] = sigs[2], sigs[1]
+
+	// a signature from another key invalidates the batch
+	sk, _, err := NewKeyPair(rand.Reader)
+	require.NoError(t, err)
+	sigs[3], err = sk.Sign(msg, nil)
+	require.NoError(t, err)
+	require.Error(t, cons.VerifyBatch(msg, pubs, sigs))
+}
+
 func TestMarshalling(t *testing.T) {
 
 	sk, pk, err := NewKeyPair(nil)
@@ -101,3 +168,28 @@ func TestMarshalling(t *testing.T) {
 	err = pk2.(*PublicKey).UnmarshalBinary(buffPK)
 	require.NoError(t, err)
 }
+
+// hashVectors are the points the messages are hashed to with the default DST.
+// Both BN256 packages must give the same points.
+var hashVectors = []struct {
+	msg   string
+	point string
+}{
+	{"", "31fc02a7ec1474eb8021906c88e3a0134a66181918cae63c03e6ee750c1d22b013446338477b5efe36c40ce08569d3989460601dfd52a82f49b4cabd7176cb46"},
+	{"abc", "541af26376bf82282ba88fa4145f473008992fa04cee0fc67533b62507f26d8935ab8dc90c07ab1f281b510cf071bebd1816a82b6a17b1f495f10ae9edbb1066"},
+	{"Peaches and Cream", "2d9d5fa63d9fa135793d64401927ab1de402a2d32bdcd4244edb383e277fde7c10cb94661bf9c9cf52db30e9737d5c663082a5c0b5a0ea530203c3bc36b1e8ac"},
+}
+
+func TestHashedMessage(t *testing.T) {
+	for _, v := range hashVectors {
+		HM, err := hashedMessage([]byte(v.msg))
+		require.NoError(t, err)
+		require.Equal(t, v.point, hex.EncodeToString(HM.Marshal()))
+	}
+
+	defer func(dst []byte) { DST = dst }(DST)
+	DST = []byte("ANOTHER DST")
+	HM, err := hashedMessage([]byte(hashVectors[0].msg))
+	require.NoError(t, err)
+	require.NotEqual(t, hashVectors[0].point, hex.EncodeToString(HM.Marshal()))
+}
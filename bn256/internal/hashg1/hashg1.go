@@ -0,0 +1,218 @@
+// Package hashg1 hashes messages to the group G1 of the BN256 curve, so that
+// the bn256 packages built on top of the cloudflare and golang implementations
+// map a message to the same point. It follows the structure of the hash to
+// curve draft of the IETF: the message is expanded with expand_message_xmd and
+// a domain separation tag into two field elements, each one is mapped to the
+// curve with the Shallue-van de Woestijne encoding as specified for BN curves
+// by Fouque and Tibouchi, and the two points are added. The cofactor of G1
+// being 1, every point of the curve is in G1.
+//
+// Unlike try-and-increment, whose number of iterations depends on the message,
+// every message goes through the same sequence of operations: the three
+// candidates of the encoding are always computed before one of them is
+// selected. The field arithmetic relies on math/big which is not constant
+// time itself; messages being public, only the structure of the computation
+// matters here.
+package hashg1
+
+import (
+	"errors"
+	"hash"
+	"math/big"
+)
+
+// p is the prime of the base field of the curve y² = x³ + 3.
+var p, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+var (
+	curveB = big.NewInt(3)
+	one    = big.NewInt(1)
+	// sqrtExp is (p+1)/4, p being 3 mod 4
+	sqrtExp = new(big.Int).Rsh(new(big.Int).Add(p, one), 2)
+	// legendreExp is (p-1)/2
+	legendreExp = new(big.Int).Rsh(new(big.Int).Sub(p, one), 1)
+	// invExp is p-2
+	invExp = new(big.Int).Sub(p, big.NewInt(2))
+	// sqrtMinus3 is a square root of -3, which exists since p is 1 mod 3
+	sqrtMinus3 = new(big.Int).Exp(new(big.Int).Sub(p, big.NewInt(3)), sqrtExp, p)
+	// cubeRoot is (-1 + sqrt(-3)) / 2, a primitive cube root of unity
+	cubeRoot = mul(sub(sqrtMinus3, one), inv(big.NewInt(2)))
+)
+
+// fieldSize is the length of a marshalled coordinate.
+const fieldSize = 32
+
+// elementSize is the number of bytes expanded for each field element: the
+// 254 bits of p plus 128 bits of security make the bias of the reduction
+// negligible.
+const elementSize = 48
+
+// maxDSTSize is the maximum length of a domain separation tag used as is by
+// expand_message_xmd. A longer tag is hashed first.
+const maxDSTSize = 255
+
+// Hash returns the point of G1 the message is hashed to, for the given domain
+// separation tag and hash function. The point is marshalled as its affine
+// coordinates x || y, each one on 32 big endian bytes, which is the format
+// both BN256 implementations unmarshal. The point at infinity is made of
+// zeros.
+func Hash(msg, dst []byte, h func() hash.Hash) ([]byte, error) {
+	uniform, err := ExpandMessageXMD(msg, dst, 2*elementSize, h)
+	if err != nil {
+		return nil, err
+	}
+	u0 := new(big.Int).SetBytes(uniform[:elementSize])
+	u1 := new(big.Int).SetBytes(uniform[elementSize:])
+	x0, y0 := encode(u0.Mod(u0, p))
+	x1, y1 := encode(u1.Mod(u1, p))
+	x, y, inf := add(x0, y0, x1, y1)
+	buff := make([]byte, 2*fieldSize)
+	if !inf {
+		putField(buff[:fieldSize], x)
+		putField(buff[fieldSize:], y)
+	}
+	return buff, nil
+}
+
+// ExpandMessageXMD implements expand_message_xmd of the hash to curve draft:
+// it derives n uniform bytes from the message and the domain separation tag
+// with the given hash function.
+func ExpandMessageXMD(msg, dst []byte, n int, h func() hash.Hash) ([]byte, error) {
+	hh := h()
+	size, block := hh.Size(), hh.BlockSize()
+	if len(dst) > maxDSTSize {
+		hh.Write([]byte("H2C-OVERSIZE-DST-"))
+		hh.Write(dst)
+		dst = hh.Sum(nil)
+	}
+	ell := (n + size - 1) / size
+	if ell > 255 || n > 0xffff {
+		return nil, errors.New("hashg1: too many bytes requested")
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+	lenInBytes := []byte{byte(n >> 8), byte(n)}
+
+	hh.Reset()
+	hh.Write(make([]byte, block))
+	hh.Write(msg)
+	hh.Write(lenInBytes)
+	hh.Write([]byte{0})
+	hh.Write(dstPrime)
+	b0 := hh.Sum(nil)
+
+	hh.Reset()
+	hh.Write(b0)
+	hh.Write([]byte{1})
+	hh.Write(dstPrime)
+	bi := hh.Sum(nil)
+	uniform := append(make([]byte, 0, ell*size), bi...)
+	for i := 2; i <= ell; i++ {
+		xored := make([]byte, size)
+		for j := range xored {
+			xored[j] = b0[j] ^ bi[j]
+		}
+		hh.Reset()
+		hh.Write(xored)
+		hh.Write([]byte{byte(i)})
+		hh.Write(dstPrime)
+		bi = hh.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:n], nil
+}
+
+// encode maps a field element t to the curve with the Shallue-van de
+// Woestijne encoding for BN curves:
+//
+//	w  = sqrt(-3) * t / (1 + b + t²)
+//	x1 = (-1 + sqrt(-3)) / 2 - t * w
+//	x2 = -1 - x1
+//	x3 = 1 + 1 / w²
+//
+// The first xi such that xi³ + b is a square is the x coordinate of the point,
+// and the sign of y is the quadratic character of t. The denominator 1 + b + t²
+// is never zero since -1 is not a square. The inverses are computed as
+// a^(p-2), so that t = 0 maps to the point of abscissa x1 = (-1 + sqrt(-3)) / 2
+// instead of failing.
+func encode(t *big.Int) (*big.Int, *big.Int) {
+	t2 := mul(t, t)
+	w := mul(mul(sqrtMinus3, t), inv(add3(one, curveB, t2)))
+	x1 := sub(cubeRoot, mul(t, w))
+	x2 := sub(new(big.Int).Neg(one), x1)
+	x3 := add3(one, inv(mul(w, w)), new(big.Int))
+
+	g1, g2, g3 := curve(x1), curve(x2), curve(x3)
+	c1, c2 := legendre(g1), legendre(g2)
+	// index of the selected candidate (c1 - 1) * c2 mod 3, computed without
+	// any early exit
+	idx := ((c1-1)*c2%3 + 3) % 3
+	xs := [3]*big.Int{x1, x2, x3}
+	gs := [3]*big.Int{g1, g2, g3}
+	x, g := xs[idx], gs[idx]
+
+	y := new(big.Int).Exp(g, sqrtExp, p)
+	negY := new(big.Int).Sub(p, y)
+	negY.Mod(negY, p)
+	ys := [2]*big.Int{y, negY}
+	return x, ys[(1-legendre(t))/2]
+}
+
+// add returns the sum of two affine points of the curve, and whether it is
+// the point at infinity.
+func add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int, bool) {
+	var lambda *big.Int
+	if x1.Cmp(x2) == 0 {
+		if y1.Cmp(y2) != 0 || y1.Sign() == 0 {
+			return nil, nil, true
+		}
+		// doubling: lambda = 3x² / 2y
+		lambda = mul(mul(big.NewInt(3), mul(x1, x1)), inv(mul(big.NewInt(2), y1)))
+	} else {
+		lambda = mul(sub(y2, y1), inv(sub(x2, x1)))
+	}
+	x3 := sub(sub(mul(lambda, lambda), x1), x2)
+	y3 := sub(mul(lambda, sub(x1, x3)), y1)
+	return x3, y3, false
+}
+
+// curve returns x³ + b.
+func curve(x *big.Int) *big.Int {
+	return add3(mul(mul(x, x), x), curveB, new(big.Int))
+}
+
+// legendre returns the quadratic character of a, zero being considered as a
+// square: 1 for a square and -1 otherwise.
+func legendre(a *big.Int) int {
+	l := new(big.Int).Exp(a, legendreExp, p)
+	if l.Cmp(one) > 0 {
+		return -1
+	}
+	return 1
+}
+
+// putField writes the field element in big endian in the given buffer, padded
+// with zeros.
+func putField(buff []byte, a *big.Int) {
+	b := a.Bytes()
+	copy(buff[len(buff)-len(b):], b)
+}
+
+func mul(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, p)
+}
+
+func sub(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	return r.Mod(r, p)
+}
+
+func add3(a, b, c *big.Int) *big.Int {
+	r := new(big.Int).Add(a, b)
+	r.Add(r, c)
+	return r.Mod(r, p)
+}
+
+func inv(a *big.Int) *big.Int {
+	return new(big.Int).Exp(a, invExp, p)
+}
@@ -0,0 +1,79 @@
+package hashg1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMessageXMD(t *testing.T) {
+	// test vectors of the hash to curve draft for SHA-256
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	var tests = []struct {
+		msg string
+		exp string
+	}{
+		{"", "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+	}
+	for _, test := range tests {
+		out, err := ExpandMessageXMD([]byte(test.msg), dst, 0x20, sha256.New)
+		require.NoError(t, err)
+		require.Equal(t, test.exp, hex.EncodeToString(out))
+	}
+	_, err := ExpandMessageXMD(nil, dst, 256*32, sha256.New)
+	require.Error(t, err)
+}
+
+func onCurve(x, y *big.Int) bool {
+	return mul(y, y).Cmp(curve(x)) == 0
+}
+
+func TestEncode(t *testing.T) {
+	require.Equal(t, new(big.Int).Sub(p, big.NewInt(3)), mul(sqrtMinus3, sqrtMinus3))
+	require.Equal(t, one, mul(cubeRoot, mul(cubeRoot, cubeRoot)))
+	for _, u := range []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(p, one)} {
+		x, y := encode(u)
+		require.True(t, onCurve(x, y), "t = %s", u)
+	}
+	// the sign of y is the quadratic character of t
+	x, y := encode(big.NewInt(1))
+	nx, ny := encode(new(big.Int).Sub(p, one))
+	require.Equal(t, x, nx)
+	require.Equal(t, legendre(big.NewInt(1)) == legendre(new(big.Int).Sub(p, one)), y.Cmp(ny) == 0)
+}
+
+func TestHash(t *testing.T) {
+	dst := []byte("HANDEL-TEST")
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		msg := []byte(fmt.Sprintf("message %d", i))
+		buff, err := Hash(msg, dst, sha256.New)
+		require.NoError(t, err)
+		require.Len(t, buff, 2*fieldSize)
+		x := new(big.Int).SetBytes(buff[:fieldSize])
+		y := new(big.Int).SetBytes(buff[fieldSize:])
+		require.True(t, onCurve(x, y))
+		require.False(t, seen[string(buff)])
+		seen[string(buff)] = true
+
+		again, err := Hash(msg, dst, sha256.New)
+		require.NoError(t, err)
+		require.Equal(t, buff, again)
+		other, err := Hash(msg, []byte("HANDEL-OTHER"), sha256.New)
+		require.NoError(t, err)
+		require.NotEqual(t, buff, other)
+	}
+
+	// addition of a point with itself and its opposite
+	x, y := encode(big.NewInt(5))
+	dx, dy, inf := add(x, y, x, y)
+	require.False(t, inf)
+	require.True(t, onCurve(dx, dy))
+	_, _, inf = add(x, y, x, sub(new(big.Int), y))
+	require.True(t, inf)
+}
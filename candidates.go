@@ -0,0 +1,235 @@
+package handel
+
+import (
+	"sort"
+)
+
+// DefaultCandidates is the number of multi-signatures per level kept by the
+// candidate store when Config.Candidates is not set.
+const DefaultCandidates = 8
+
+// candidateExactLimit is the number of candidates up to which the candidate
+// store searches the best combination exhaustively. Above, it picks the
+// candidates greedily.
+const candidateExactLimit = 12
+
+// candidateStore is a SignatureStore keeping, for each level, a bounded set of
+// verified multi-signatures, the candidates, instead of only the best one.
+// Two overlapping multi-signatures can not be combined, but each one may
+// later be combined with a third one disjoint from it: the candidate store
+// keeps both and computes the best of a level as the best combination of
+// pairwise disjoint candidates, completed with the verified individual
+// signatures.
+type candidateStore struct {
+	*store
+	// maximum number of candidates per level
+	max int
+	// the candidates of each level
+	candidates map[byte][]*MultiSignature
+}
+
+// newCandidateStore returns a candidate store keeping up to max candidates per
+// level, on top of the given store. The given store must not be used anymore.
+func newCandidateStore(s *store, max int) *candidateStore {
+	if max < 1 {
+		max = DefaultCandidates
+	}
+	// the best of a level is computed over the whole level, even if it is made
+	// of multiple groups
+	s.flat = true
+	return &candidateStore{
+		store:      s,
+		max:        max,
+		candidates: make(map[byte][]*MultiSignature),
+	}
+}
+
+func (c *candidateStore) Store(sp *incomingSig) *MultiSignature {
+	c.Lock()
+	defer c.Unlock()
+	if sp.Individual() {
+		if sp.ms.BitSet.Cardinality() != 1 {
+			panic("bad individual sig")
+		}
+		c.indivSigsVerified[sp.level].Set(sp.mappedIndex, true)
+		c.individualSigs[sp.level][sp.mappedIndex] = sp.ms
+	} else {
+		c.addCandidate(sp.level, sp.ms)
+	}
+
+	selected := c.selectCandidates(sp.level)
+	if len(c.candidates[sp.level]) > c.max {
+		c.evict(sp.level, selected)
+		selected = c.selectCandidates(sp.level)
+	}
+	best := c.combineCandidates(sp.level, selected)
+	if best.Cardinality() == 0 {
+		return nil
+	}
+	if cur := c.m[sp.level]; cur != nil && !c.better(sp.level, best.BitSet, cur.BitSet) {
+		return nil
+	}
+	c.store.store(sp.level, best)
+	return best
+}
+
+func (c *candidateStore) Evaluate(sp *incomingSig) int {
+	c.Lock()
+	defer c.Unlock()
+	score := c.unsafeEvaluate(sp)
+	if score > 0 || sp.Individual() {
+		return score
+	}
+	best := c.m[sp.level]
+	if best != nil && best.Cardinality() == c.part.Size(int(sp.level)) {
+		return 0
+	}
+	for _, cand := range c.candidates[sp.level] {
+		if cand.IsSuperSet(sp.ms.BitSet) {
+			return 0
+		}
+	}
+	// it does not improve the best right now but may be combined later
+	return 1
+}
+
+// addCandidate adds the multi-signature to the candidates of the level,
+// unless one of them already contains all its contributions. The candidates
+// whose contributions are all in the new one are removed.
+func (c *candidateStore) addCandidate(level byte, ms *MultiSignature) {
+	var kept []*MultiSignature
+	for _, cand := range c.candidates[level] {
+		if cand.IsSuperSet(ms.BitSet) {
+			return
+		}
+		if !ms.IsSuperSet(cand.BitSet) {
+			kept = append(kept, cand)
+		}
+	}
+	c.candidates[level] = append(kept, ms)
+}
+
+// evict bounds the number of candidates of the level: the selected candidates
+// are merged into a single one, then the smallest other candidates are
+// removed.
+func (c *candidateStore) evict(level byte, selected []int) {
+	cands := c.candidates[level]
+	inSelection := make(map[int]bool)
+	for _, i := range selected {
+		inSelection[i] = true
+	}
+	var others []*MultiSignature
+	for i, cand := range cands {
+		if !inSelection[i] {
+			others = append(others, cand)
+		}
+	}
+	merged := c.combineCandidates(level, selected)
+	if merged.Cardinality() == 0 {
+		merged = nil
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return c.better(level, others[i].BitSet, others[j].BitSet)
+	})
+	kept := make([]*MultiSignature, 0, c.max)
+	if merged != nil {
+		kept = append(kept, &MultiSignature{BitSet: merged.BitSet, Signature: merged.Signature})
+	}
+	for _, cand := range others {
+		if len(kept) == c.max {
+			break
+		}
+		kept = append(kept, cand)
+	}
+	c.candidates[level] = kept
+}
+
+// selectCandidates returns the indexes of the pairwise disjoint candidates of
+// the level whose combination, completed with the verified individual
+// signatures, is the best. The search is exhaustive for up to
+// candidateExactLimit candidates and greedy above.
+func (c *candidateStore) selectCandidates(level byte) []int {
+	cands := c.candidates[level]
+	indiv := c.indivSigsVerified[level]
+	if len(cands) > candidateExactLimit {
+		return c.greedySelection(level)
+	}
+	var best []int
+	var bestSet BitSet
+	var search func(i int, chosen []int, union BitSet)
+	search = func(i int, chosen []int, union BitSet) {
+		if i == len(cands) {
+			set := union.Or(indiv)
+			if bestSet == nil || c.better(level, set, bestSet) {
+				best = append([]int{}, chosen...)
+				bestSet = set
+			}
+			return
+		}
+		if union.IntersectionCardinality(cands[i].BitSet) == 0 {
+			search(i+1, append(chosen, i), union.Or(cands[i].BitSet))
+		}
+		search(i+1, chosen, union)
+	}
+	search(0, nil, c.nbs(indiv.BitLength()))
+	return best
+}
+
+// greedySelection picks the candidates from the best to the worst, skipping
+// the ones overlapping the candidates already picked.
+func (c *candidateStore) greedySelection(level byte) []int {
+	cands := c.candidates[level]
+	order := make([]int, len(cands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return c.better(level, cands[order[i]].BitSet, cands[order[j]].BitSet)
+	})
+	union := c.nbs(c.indivSigsVerified[level].BitLength())
+	var selected []int
+	for _, i := range order {
+		if union.IntersectionCardinality(cands[i].BitSet) != 0 {
+			continue
+		}
+		union = union.Or(cands[i].BitSet)
+		selected = append(selected, i)
+	}
+	return selected
+}
+
+// combineCandidates combines the given candidates of the level and completes
+// the result with the verified individual signatures.
+func (c *candidateStore) combineCandidates(level byte, selected []int) *MultiSignature {
+	cands := c.candidates[level]
+	indiv := c.indivSigsVerified[level]
+	best := &MultiSignature{BitSet: c.nbs(indiv.BitLength())}
+	for _, i := range selected {
+		best.BitSet = best.BitSet.Or(cands[i].BitSet)
+		if best.Signature == nil {
+			best.Signature = cands[i].Signature
+		} else {
+			best.Signature = best.Signature.Combine(cands[i].Signature)
+		}
+	}
+	missing := best.BitSet.And(indiv).Xor(indiv)
+	for pos, ok := missing.NextSet(0); ok; pos, ok = missing.NextSet(pos + 1) {
+		sig := c.individualSigs[level][pos]
+		best.BitSet.Set(pos, true)
+		if best.Signature == nil {
+			best.Signature = sig.Signature
+		} else {
+			best.Signature = sig.Combine(best.Signature)
+		}
+	}
+	return best
+}
+
+// better returns true if the contributions of bs1 are better than the ones of
+// bs2: heavier in weighted mode, more numerous otherwise.
+func (c *candidateStore) better(level byte, bs1, bs2 BitSet) bool {
+	if c.weights != nil {
+		return c.heavier(level, bs1, bs2)
+	}
+	return bs1.Cardinality() > bs2.Cardinality()
+}
@@ -0,0 +1,96 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// candidateSig returns a multi-signature of the level 4 of the node 0 in a
+// registry of 16 nodes with the given contributions set.
+func candidateSig(bits ...int) *incomingSig {
+	bs := NewWilffBitset(8)
+	for _, b := range bits {
+		bs.Set(b, true)
+	}
+	return &incomingSig{origin: 8, level: 4, ms: newSig(bs)}
+}
+
+func TestCandidateStoreDisjoint(t *testing.T) {
+	reg := FakeRegistry(16)
+	part := NewBinPartitioner(0, reg, DefaultLogger)
+	store := newCandidateStore(newStore(part, NewWilffBitset, new(fakeCons)), DefaultCandidates)
+
+	a := candidateSig(0, 1, 2)
+	b := candidateSig(2, 3, 4, 5)
+	c := candidateSig(3, 4, 5, 6)
+
+	require.True(t, store.Evaluate(a) > 0)
+	require.NotNil(t, store.Store(a))
+	require.True(t, store.Evaluate(b) > 0)
+	require.NotNil(t, store.Store(b))
+	best, _ := store.Best(4)
+	require.Equal(t, 4, best.Cardinality())
+
+	// c overlaps with b and is not better, but it is kept as a candidate
+	require.True(t, store.Evaluate(c) > 0)
+	best = store.Store(c)
+	require.NotNil(t, best)
+	// a and c are disjoint and combine into a better signature than b
+	require.Equal(t, 7, best.Cardinality())
+	for i := 0; i < 7; i++ {
+		require.True(t, best.Get(i))
+	}
+
+	// a candidate contained in another one is useless
+	require.Equal(t, 0, store.Evaluate(candidateSig(3, 4)))
+	// an individual signature completes the best combination
+	best = store.Store(&incomingSig{origin: 15, level: 4, ms: candidateSig(7).ms, isInd: true, mappedIndex: 7})
+	require.NotNil(t, best)
+	require.Equal(t, 8, best.Cardinality())
+	require.Equal(t, 0, store.Evaluate(candidateSig(0, 7)))
+}
+
+func TestCandidateStoreEviction(t *testing.T) {
+	reg := FakeRegistry(16)
+	part := NewBinPartitioner(0, reg, DefaultLogger)
+	max := 3
+	store := newCandidateStore(newStore(part, NewWilffBitset, new(fakeCons)), max)
+
+	// consecutive signatures overlap
+	for i := 0; i < 6; i++ {
+		store.Store(candidateSig(i, i+1))
+		require.True(t, len(store.candidates[4]) <= max)
+	}
+	// the merged candidates are kept: {0,1}, {2,3} and {4,5}
+	best, _ := store.Best(4)
+	require.Equal(t, 6, best.Cardinality())
+
+	// the evicted candidates are replaced by the better ones
+	store.Store(candidateSig(0, 1, 2, 3, 4))
+	require.True(t, len(store.candidates[4]) <= max)
+	best, _ = store.Best(4)
+	require.True(t, best.Cardinality() >= 5)
+}
+
+func TestHandelCandidates(t *testing.T) {
+	n := 32
+	config := DefaultConfig(n)
+	config.Candidates = DefaultCandidates
+	config.NewTimeoutStrategy = newInfiniteTimeout
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	test.Start()
+	defer test.Stop()
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(10 * time.Second):
+		t.Fatal("handel did not complete")
+	}
+}
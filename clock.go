@@ -0,0 +1,257 @@
+package handel
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the passing of time for Handel. All the timers, tickers and
+// sleeps of Handel go through the Clock of its Config, so tests can drive a
+// whole round with a ManualClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep pauses the current goroutine for at least the given duration.
+	Sleep(d time.Duration)
+	// NewTicker returns a Ticker delivering the time on its channel every
+	// period. The period must be positive.
+	NewTicker(period time.Duration) Ticker
+	// AfterFunc calls f once the duration has elapsed. It returns a Timer
+	// that can cancel the call.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks at regular intervals, as a time.Ticker.
+type Ticker interface {
+	// Chan returns the channel on which the ticks are delivered.
+	Chan() <-chan time.Time
+	// Stop turns off the ticker. No more ticks are sent after it returns.
+	Stop()
+}
+
+// Timer is a call scheduled by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the call from happening. It returns false if the call has
+	// already happened or has already been stopped.
+	Stop() bool
+}
+
+// RealClock returns the Clock using the time package. It is the default
+// Clock of Handel.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) NewTicker(period time.Duration) Ticker {
+	return &realTicker{time.NewTicker(period)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (r *realTicker) Chan() <-chan time.Time {
+	return r.C
+}
+
+// ManualClock is a Clock whose time only passes when Advance or AdvanceNext
+// is called. Tickers, timers and sleeps are triggered in the order of their
+// deadlines as time passes, so a whole Handel round can be driven step by
+// step. The functions given to AfterFunc are called synchronously by the
+// goroutine advancing the clock. As with time.Ticker, a tick is dropped if the
+// previous one has not been read yet.
+//
+// A ManualClock only controls when timeouts and periodic tasks fire. The
+// network and the verification of signatures still run in their own
+// goroutines, and the clock can not tell whether Handel is idle. A test must
+// therefore synchronize on observable events, e.g. the callbacks triggered by
+// a tick, before advancing the clock again; advancing it while Handel is busy
+// makes the outcome depend on the scheduling of goroutines.
+type ManualClock struct {
+	sync.Mutex
+	now time.Time
+	// pending tickers, timers and sleeps ordered by deadline
+	waiters []*manualWaiter
+}
+
+// manualWaiter is a ticker, timer or sleep pending on a ManualClock.
+type manualWaiter struct {
+	clock    *ManualClock
+	deadline time.Time
+	// period of the ticker, zero for timers and sleeps
+	period time.Duration
+	// channel of the ticker
+	c chan time.Time
+	// function called by timers and sleeps
+	f func()
+}
+
+// NewManualClock returns a ManualClock whose current time is start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now implements the Clock interface.
+func (m *ManualClock) Now() time.Time {
+	m.Lock()
+	defer m.Unlock()
+	return m.now
+}
+
+// Sleep implements the Clock interface. It blocks until the clock has been
+// advanced by at least the given duration.
+func (m *ManualClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	m.AfterFunc(d, func() { close(done) })
+	<-done
+}
+
+// NewTicker implements the Clock interface.
+func (m *ManualClock) NewTicker(period time.Duration) Ticker {
+	if period <= 0 {
+		panic("handel: non-positive interval for NewTicker")
+	}
+	w := &manualWaiter{clock: m, period: period, c: make(chan time.Time, 1)}
+	m.schedule(w, period)
+	return &manualTicker{w}
+}
+
+// AfterFunc implements the Clock interface.
+func (m *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &manualWaiter{clock: m, f: f}
+	m.schedule(w, d)
+	return w
+}
+
+// Advance moves the time forward by the given duration, triggering all the
+// tickers, timers and sleeps whose deadline is reached on the way.
+func (m *ManualClock) Advance(d time.Duration) {
+	m.Lock()
+	target := m.now.Add(d)
+	m.Unlock()
+	for m.fireNext(target) {
+	}
+	m.Lock()
+	if target.After(m.now) {
+		m.now = target
+	}
+	m.Unlock()
+}
+
+// AdvanceNext moves the time forward to the next deadline of the pending
+// tickers, timers and sleeps, and triggers them. It returns the duration by
+// which the time moved and false if nothing is pending.
+func (m *ManualClock) AdvanceNext() (time.Duration, bool) {
+	m.Lock()
+	if len(m.waiters) == 0 {
+		m.Unlock()
+		return 0, false
+	}
+	start := m.now
+	target := m.waiters[0].deadline
+	m.Unlock()
+	m.Advance(target.Sub(start))
+	return target.Sub(start), true
+}
+
+// Pending returns the number of tickers, timers and sleeps pending on the
+// clock.
+func (m *ManualClock) Pending() int {
+	m.Lock()
+	defer m.Unlock()
+	return len(m.waiters)
+}
+
+// fireNext triggers the first pending waiter if its deadline is before the
+// target. It returns false if there is none.
+func (m *ManualClock) fireNext(target time.Time) bool {
+	m.Lock()
+	if len(m.waiters) == 0 || m.waiters[0].deadline.After(target) {
+		m.Unlock()
+		return false
+	}
+	w := m.waiters[0]
+	m.waiters = m.waiters[1:]
+	if w.deadline.After(m.now) {
+		m.now = w.deadline
+	}
+	now := m.now
+	if w.period > 0 {
+		m.insert(w, now.Add(w.period))
+		m.Unlock()
+		select {
+		case w.c <- now:
+		default:
+		}
+		return true
+	}
+	m.Unlock()
+	w.f()
+	return true
+}
+
+func (m *ManualClock) schedule(w *manualWaiter, d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.insert(w, m.now.Add(d))
+}
+
+// insert adds the waiter with the given deadline after all the waiters with
+// an earlier or equal deadline.
+func (m *ManualClock) insert(w *manualWaiter, deadline time.Time) {
+	w.deadline = deadline
+	i := sort.Search(len(m.waiters), func(i int) bool {
+		return m.waiters[i].deadline.After(deadline)
+	})
+	m.waiters = append(m.waiters, nil)
+	copy(m.waiters[i+1:], m.waiters[i:])
+	m.waiters[i] = w
+}
+
+// remove removes the waiter and returns true if it was pending.
+func (m *ManualClock) remove(w *manualWaiter) bool {
+	m.Lock()
+	defer m.Unlock()
+	for i, v := range m.waiters {
+		if v == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *manualWaiter) Stop() bool {
+	return w.clock.remove(w)
+}
+
+// manualTicker is the Ticker of a ManualClock.
+type manualTicker struct {
+	w *manualWaiter
+}
+
+func (t *manualTicker) Chan() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *manualTicker) Stop() {
+	t.w.clock.remove(t.w)
+}
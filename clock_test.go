@@ -0,0 +1,128 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewManualClock(start)
+	require.Equal(t, start, c.Now())
+
+	var calls []int
+	c.AfterFunc(20*time.Millisecond, func() { calls = append(calls, 20) })
+	timer := c.AfterFunc(30*time.Millisecond, func() { calls = append(calls, 30) })
+	c.AfterFunc(10*time.Millisecond, func() { calls = append(calls, 10) })
+	ticker := c.NewTicker(15 * time.Millisecond)
+	require.Equal(t, 4, c.Pending())
+
+	c.Advance(5 * time.Millisecond)
+	require.Empty(t, calls)
+	require.Equal(t, start.Add(5*time.Millisecond), c.Now())
+
+	c.Advance(20 * time.Millisecond)
+	require.Equal(t, []int{10, 20}, calls)
+	require.Equal(t, start.Add(15*time.Millisecond), <-ticker.Chan())
+
+	require.True(t, timer.Stop())
+	require.False(t, timer.Stop())
+	d, ok := c.AdvanceNext()
+	require.True(t, ok)
+	require.Equal(t, 5*time.Millisecond, d)
+	require.Equal(t, start.Add(30*time.Millisecond), <-ticker.Chan())
+	require.Equal(t, []int{10, 20}, calls)
+
+	// unread ticks are dropped
+	c.Advance(45 * time.Millisecond)
+	require.Equal(t, start.Add(45*time.Millisecond), <-ticker.Chan())
+	select {
+	case <-ticker.Chan():
+		t.Fatal("tick not dropped")
+	default:
+	}
+	ticker.Stop()
+	require.Equal(t, 0, c.Pending())
+	_, ok = c.AdvanceNext()
+	require.False(t, ok)
+
+	slept := make(chan bool)
+	go func() {
+		c.Sleep(time.Second)
+		slept <- true
+	}()
+	for c.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Advance(time.Second)
+	<-slept
+}
+
+// chanNetwork outputs all the packets sent on a channel.
+type chanNetwork chan *Packet
+
+func (c chanNetwork) RegisterListener(l Listener) {}
+
+func (c chanNetwork) Send(ids []Identity, p *Packet) {
+	for range ids {
+		c <- p
+	}
+}
+
+func TestHandelManualClock(t *testing.T) {
+	n := 16
+	clock := NewManualClock(time.Unix(0, 0))
+	reg := FakeRegistry(n)
+	id, _ := reg.Identity(0)
+	net := make(chanNetwork, n)
+	config := &Config{
+		Clock:              clock,
+		UpdateCount:        1,
+		NewTimeoutStrategy: newInfiniteTimeout,
+	}
+	h := NewHandel(net, reg, id, new(fakeCons), msg, &fakeSig{true}, config)
+	h.Start()
+	defer h.Stop()
+	h.StartLevel(4)
+	require.Equal(t, byte(4), (<-net).Level)
+
+	// the periodic updates only happen once the clock passes the update
+	// period: the first one contacts the single peer of the first level and
+	// another peer of the fourth level
+	clock.Advance(DefaultUpdatePeriod - time.Millisecond)
+	require.Len(t, net, 0)
+	clock.Advance(time.Millisecond)
+	levels := map[byte]bool{(<-net).Level: true, (<-net).Level: true}
+	require.Equal(t, map[byte]bool{1: true, 4: true}, levels)
+	for i := 0; i < 3; i++ {
+		clock.Advance(DefaultUpdatePeriod)
+		require.Equal(t, byte(4), (<-net).Level)
+	}
+}
+
+func TestTimeoutManualClock(t *testing.T) {
+	n := 8
+	clock := NewManualClock(time.Unix(0, 0))
+	_, handels := fakeSetupWithConfig(n, &Config{Clock: clock})
+	defer CloseHandels(handels)
+	levels := []int{1, 2, 3}
+	period := 20 * time.Millisecond
+	linear := NewLinearTimeout(handels[0], levels, period).(*linearTimeout)
+	started := make(chan int, len(levels))
+	linear.newLevel = func(level int) {
+		started <- level
+	}
+	linear.Start()
+	defer linear.Stop()
+
+	// each level starts once the clock passes its timeout
+	require.Equal(t, 1, <-started)
+	for _, lvl := range levels[1:] {
+		clock.Advance(period - time.Millisecond)
+		require.Len(t, started, 0)
+		clock.Advance(time.Millisecond)
+		require.Equal(t, lvl, <-started)
+	}
+}
@@ -0,0 +1,106 @@
+package handel
+
+import (
+	"errors"
+	"time"
+)
+
+// CompletionPolicy decides when Handel completes the round by itself. Once
+// completed, Handel closes the FinalSignatures channel. It may keep helping its
+// peers for a linger period before stopping all its sub-routines.
+type CompletionPolicy interface {
+	// Complete is called each time Handel outputs a new final signature. size
+	// is the number of nodes in the registry. It returns after how long Handel
+	// completes: immediately if zero, not yet if negative. Only the first
+	// positive delay returned is taken into account.
+	Complete(ms *MultiSignature, size int) time.Duration
+	// Deadline returns after how long, since the start of the round, Handel
+	// completes in any case. Zero means no deadline.
+	Deadline() time.Duration
+	// Linger returns for how long Handel keeps helping its peers after having
+	// completed: it keeps verifying signatures and sending updates, but does
+	// not output final signatures anymore. Handel stops when it elapses.
+	Linger() time.Duration
+}
+
+// ErrDeadline is returned by Err when Handel has been stopped by the deadline
+// of its completion policy before having output any final signature.
+var ErrDeadline = errors.New("handel: completion deadline reached")
+
+// completionPolicy is a CompletionPolicy completing the round at the first
+// final signature, at the first full signature, after a grace period or never.
+type completionPolicy struct {
+	// never complete on a final signature
+	never bool
+	// only complete with a full signature
+	full bool
+	// delay after the first final signature, if positive
+	grace  time.Duration
+	linger time.Duration
+}
+
+// ThresholdCompletion returns a CompletionPolicy completing the round as soon
+// as a final signature reaches the threshold. Handel keeps helping its peers
+// during the given linger period.
+func ThresholdCompletion(linger time.Duration) CompletionPolicy {
+	return &completionPolicy{linger: linger}
+}
+
+// FullCompletion returns a CompletionPolicy completing the round as soon as a
+// final signature contains the contributions of all nodes. Handel keeps
+// helping its peers during the given linger period.
+func FullCompletion(linger time.Duration) CompletionPolicy {
+	return &completionPolicy{full: true, linger: linger}
+}
+
+// GraceCompletion returns a CompletionPolicy completing the round after a
+// grace period following the first final signature reaching the threshold,
+// or as soon as a final signature contains the contributions of all nodes.
+// Handel keeps helping its peers during the given linger period.
+func GraceCompletion(grace, linger time.Duration) CompletionPolicy {
+	return &completionPolicy{grace: grace, linger: linger}
+}
+
+// DeadlineCompletion returns a CompletionPolicy completing the round only at
+// the given deadline after its start. Handel keeps helping its peers during
+// the given linger period.
+func DeadlineCompletion(deadline, linger time.Duration) CompletionPolicy {
+	return WithDeadline(&completionPolicy{never: true, linger: linger}, deadline)
+}
+
+func (c *completionPolicy) Complete(ms *MultiSignature, size int) time.Duration {
+	switch {
+	case c.never:
+		return -1
+	case ms.Cardinality() == size:
+		return 0
+	case c.full:
+		return -1
+	default:
+		return c.grace
+	}
+}
+
+func (c *completionPolicy) Deadline() time.Duration {
+	return 0
+}
+
+func (c *completionPolicy) Linger() time.Duration {
+	return c.linger
+}
+
+// deadlinePolicy adds a deadline to another CompletionPolicy.
+type deadlinePolicy struct {
+	CompletionPolicy
+	deadline time.Duration
+}
+
+// WithDeadline returns the given CompletionPolicy completing the round in any
+// case at the given deadline after its start.
+func WithDeadline(p CompletionPolicy, deadline time.Duration) CompletionPolicy {
+	return &deadlinePolicy{CompletionPolicy: p, deadline: deadline}
+}
+
+func (d *deadlinePolicy) Deadline() time.Duration {
+	return d.deadline
+}
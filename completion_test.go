@@ -0,0 +1,126 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionPolicies(t *testing.T) {
+	n := 8
+	full := &MultiSignature{BitSet: finalBitset(n)}
+	partial := &MultiSignature{BitSet: finalBitset(n)}
+	partial.BitSet.Set(0, false)
+
+	p := ThresholdCompletion(0)
+	require.Equal(t, time.Duration(0), p.Complete(partial, n))
+	require.Equal(t, time.Duration(0), p.Deadline())
+
+	p = FullCompletion(time.Second)
+	require.True(t, p.Complete(partial, n) < 0)
+	require.Equal(t, time.Duration(0), p.Complete(full, n))
+	require.Equal(t, time.Second, p.Linger())
+
+	p = GraceCompletion(time.Second, 0)
+	require.Equal(t, time.Second, p.Complete(partial, n))
+	require.Equal(t, time.Duration(0), p.Complete(full, n))
+
+	p = DeadlineCompletion(time.Second, 0)
+	require.True(t, p.Complete(full, n) < 0)
+	require.Equal(t, time.Second, p.Deadline())
+
+	p = WithDeadline(ThresholdCompletion(0), time.Minute)
+	require.Equal(t, time.Duration(0), p.Complete(partial, n))
+	require.Equal(t, time.Minute, p.Deadline())
+}
+
+// collect reads the final signatures of h until the channel is closed.
+func collect(t *testing.T, h *Handel) []MultiSignature {
+	var sigs []MultiSignature
+	for {
+		select {
+		case ms, open := <-h.FinalSignatures():
+			if !open {
+				return sigs
+			}
+			sigs = append(sigs, ms)
+		case <-time.After(5 * time.Second):
+			t.Fatal("final signatures channel not closed")
+		}
+	}
+}
+
+func TestHandelCompletion(t *testing.T) {
+	n := 16
+	_, handels := fakeSetupWithConfig(n, &Config{Completion: FullCompletion(0)})
+	defer CloseHandels(handels)
+	for _, h := range handels {
+		h.Start()
+	}
+	for _, h := range handels {
+		sigs := collect(t, h)
+		require.NotEmpty(t, sigs)
+		require.Equal(t, n, sigs[len(sigs)-1].Cardinality())
+		<-h.Done()
+		require.Equal(t, ErrCompleted, h.Err())
+	}
+}
+
+func TestHandelCompletionDeadline(t *testing.T) {
+	n := 16
+	_, handels := fakeSetupWithConfig(n, &Config{Completion: DeadlineCompletion(50*time.Millisecond, 0)})
+	defer CloseHandels(handels)
+	// no peer is running
+	h := handels[1]
+	h.Start()
+	require.Empty(t, collect(t, h))
+	<-h.Done()
+	require.Equal(t, ErrDeadline, h.Err())
+}
+
+func TestHandelCompletionLinger(t *testing.T) {
+	n := 16
+	linger := 200 * time.Millisecond
+	_, handels := fakeSetupWithConfig(n, &Config{Completion: ThresholdCompletion(linger)})
+	defer CloseHandels(handels)
+	for _, h := range handels {
+		h.Start()
+	}
+	h := handels[1]
+	require.Len(t, collect(t, h), 1)
+	// still helping peers
+	select {
+	case <-h.Done():
+		t.Fatal("handel stopped before the linger period")
+	default:
+	}
+	require.Nil(t, h.Err())
+	select {
+	case <-h.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("handel not stopped after the linger period")
+	}
+	require.Equal(t, ErrCompleted, h.Err())
+}
+
+func TestCompletionWithTest(t *testing.T) {
+	n := 8
+	config := DefaultConfig(n)
+	config.Completion = DeadlineCompletion(50*time.Millisecond, 0)
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	// the round never completes, the deadline closes the final signatures
+	test.SetOfflineNodes(0)
+	test.Start()
+	for _, h := range test.handels[1:] {
+		<-h.Done()
+		require.Error(t, h.Err())
+	}
+	test.Stop()
+}
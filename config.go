@@ -18,6 +18,26 @@ type Config struct {
 	// default.
 	Contributions int
 
+	// Weighted makes Handel use the weights of the nodes instead of their
+	// number, as given by a WeightedRegistry or by WeightedIdentity. Handel
+	// only outputs multi-signatures whose summed weight of contributions
+	// reaches ContributionsWeight, and verifies first the signatures adding the
+	// most weight. Contributions is ignored in this mode.
+	Weighted bool
+
+	// ContributionsWeight is the minimum summed weight of the contributions a
+	// multi-signature must contain to be considered as valid in weighted
+	// mode. If not specified, DefaultContributionsPerc of the total weight of
+	// the registry is used by default.
+	ContributionsWeight int64
+
+	// Candidates, if positive, makes Handel keep up to this number of verified
+	// multi-signatures per level instead of only the best one. The best
+	// multi-signature of a level is then the best combination of disjoint
+	// candidates, so overlapping multi-signatures are not lost. If zero,
+	// Handel keeps only the best multi-signature of each level.
+	Candidates int
+
 	// UpdatePeriod indicates at which frequency a Handel nodes sends updates
 	// about its state to other Handel nodes.
 	UpdatePeriod time.Duration
@@ -35,8 +55,10 @@ type Config struct {
 	NewBitSet func(bitlength int) BitSet
 
 	// NewPartitioner returns the Partitioner to use for this Handel round. If
-	// nil, it returns the RandomBinPartitioner. The id is the ID Handel is
-	// responsible for and reg is the global registry of participants.
+	// nil, it returns the BinPartitioner. See RandomBinPartitionerConstructor
+	// to build the levels over a permutation of the registry. The id is the ID
+	// Handel is responsible for and reg is the global registry of
+	// participants.
 	NewPartitioner func(id int32, reg Registry, Logger Logger) Partitioner
 
 	// NewEvaluatorStrategy returns the signature evaluator to use during the
@@ -47,6 +69,26 @@ type Config struct {
 	// round. By default, it uses the linear timeout strategy.
 	NewTimeoutStrategy func(h *Handel, levels []int) TimeoutStrategy
 
+	// NewReputation returns the Reputation used to penalize misbehaving peers
+	// and to decide how their packets are treated. By default, packets from
+	// peers having DefaultMisbehaviourThreshold misbehaviours are
+	// deprioritized.
+	NewReputation func(reg Registry) Reputation
+
+	// Completion is the policy deciding when Handel completes the round by
+	// itself and closes the FinalSignatures channel. If nil, Handel runs until
+	// Stop is called or until the context given to Run is done.
+	Completion CompletionPolicy
+
+	// Observer is notified of the progress of the Handel round. By default,
+	// nothing is notified.
+	Observer Observer
+
+	// Clock is used by Handel for all its timers, tickers and sleeps. By
+	// default, it is the RealClock. Tests can use a ManualClock to drive a
+	// round deterministically.
+	Clock Clock
+
 	// Logger to use for logging handel actions
 	Logger Logger
 	// Rand provides the source of entropy for shuffling the list of nodes that
@@ -58,6 +100,39 @@ type Config struct {
 	// is much easier to detect pattern in bugs in this manner
 	DisableShuffling bool
 
+	// VerifyWorkers is the number of signatures Handel verifies concurrently.
+	// Each worker picks the best signature left to verify according to the
+	// evaluator strategy. By default, one signature is verified at a time.
+	VerifyWorkers int
+
+	// VerifyBatchSize is the maximum number of signatures Handel verifies at
+	// once in a batch. It is only used if the Constructor implements the
+	// BatchVerifier interface. By default, signatures are verified one by one.
+	VerifyBatchSize int
+
+	// KeyCache caches the aggregate public key of each level, from which
+	// Handel derives the aggregate keys of the signatures it verifies. It can
+	// be shared between the rounds and the nodes of a process running over the
+	// same registry. If nil, each Handel uses its own cache.
+	KeyCache *KeyCache
+
+	// Snapshot, if not nil, is a snapshot returned by Handel.Snapshot from
+	// which Handel resumes the round, typically after its process restarted:
+	// the verified signatures and the state of the levels are restored instead
+	// of being built again from scratch. An invalid snapshot, or the snapshot
+	// of another round, is logged and ignored.
+	Snapshot []byte
+
+	// MaxQueueSize is the maximum number of signatures waiting for
+	// verification. When the queue is full, the signature dropped is chosen
+	// according to the QueuePolicy. DefaultMaxQueueSize by default.
+	MaxQueueSize int
+
+	// QueuePolicy tells which signature is dropped when the queue of
+	// signatures waiting for verification is full. By default, it is
+	// EvictLowestScore.
+	QueuePolicy QueuePolicy
+
 	// UnsafeSleepTimeOnSigVerify is a test feature a sleep time (in ms) rather than actually verifying the signatures
 	// Can be used to save on CPU during tests or/and to test with shorter/longer verifying time
 	// Set to zero by default: no sleep time. When activated the sleep replaces the verification.
@@ -73,10 +148,16 @@ func DefaultConfig(numberOfNodes int) *Config {
 		FastPath:             DefaultCandidateCount,
 		UpdatePeriod:         DefaultUpdatePeriod,
 		UpdateCount:          DefaultUpdateCount,
+		VerifyWorkers:        DefaultVerifyWorkers,
+		VerifyBatchSize:      DefaultVerifyBatchSize,
+		MaxQueueSize:         DefaultMaxQueueSize,
 		NewBitSet:            DefaultBitSet,
 		NewPartitioner:       DefaultPartitioner,
 		NewEvaluatorStrategy: DefaultEvaluatorStrategy,
 		NewTimeoutStrategy:   DefaultTimeoutStrategy,
+		NewReputation:        DefaultReputation,
+		Observer:             NoopObserver{},
+		Clock:                RealClock(),
 		Logger:               DefaultLogger,
 		Rand:                 rand.Reader,
 	}
@@ -96,6 +177,18 @@ const DefaultUpdatePeriod = 10 * time.Millisecond
 // update
 const DefaultUpdateCount = 1
 
+// DefaultVerifyWorkers is the default number of signatures verified
+// concurrently by Handel.
+const DefaultVerifyWorkers = 1
+
+// DefaultVerifyBatchSize is the default maximum number of signatures verified
+// at once by Handel, i.e. no batch verification.
+const DefaultVerifyBatchSize = 1
+
+// DefaultMaxQueueSize is the default maximum number of signatures waiting for
+// verification.
+const DefaultMaxQueueSize = 10000
+
 // DefaultBitSet returns the default implementation used by Handel, i.e. the
 // WilffBitSet
 var DefaultBitSet = func(bitlength int) BitSet { return NewWilffBitset(bitlength) }
@@ -118,6 +211,15 @@ func DefaultTimeoutStrategy(h *Handel, levels []int) TimeoutStrategy {
 	return NewDefaultLinearTimeout(h, levels)
 }
 
+// DefaultReputation returns the default reputation used by handel: the packets
+// of peers are deprioritized once they have misbehaved
+// DefaultMisbehaviourThreshold times. The packets are not dropped since the
+// origin of a packet is not authenticated: a byzantine node could otherwise
+// get an honest peer ignored by sending invalid packets on its behalf.
+func DefaultReputation(reg Registry) Reputation {
+	return NewThresholdReputation(DefaultMisbehaviourThreshold, DeprioritizePacket)
+}
+
 // PercentageToContributions returns the exact number of contributions needed
 // out of n contributions, from the given percentage. Useful when considering
 // large scale signatures as in Handel, e.g. 51%, 75%...
@@ -125,6 +227,13 @@ func PercentageToContributions(perc, n int) int {
 	return int(math.Ceil(float64(n) * float64(perc) / 100.0))
 }
 
+// PercentageToWeight returns the exact weight needed out of the total weight,
+// from the given percentage.
+func PercentageToWeight(perc int, total int64) int64 {
+	p := int64(perc)
+	return total/100*p + (total%100*p+99)/100
+}
+
 func mergeWithDefault(c *Config, size int) *Config {
 	c2 := *c
 	if c.Contributions == 0 {
@@ -140,6 +249,15 @@ func mergeWithDefault(c *Config, size int) *Config {
 	if c.UpdateCount == 0 {
 		c2.UpdateCount = DefaultUpdateCount
 	}
+	if c.VerifyWorkers == 0 {
+		c2.VerifyWorkers = DefaultVerifyWorkers
+	}
+	if c.VerifyBatchSize == 0 {
+		c2.VerifyBatchSize = DefaultVerifyBatchSize
+	}
+	if c.MaxQueueSize == 0 {
+		c2.MaxQueueSize = DefaultMaxQueueSize
+	}
 	if c.NewBitSet == nil {
 		c2.NewBitSet = DefaultBitSet
 	}
@@ -152,6 +270,15 @@ func mergeWithDefault(c *Config, size int) *Config {
 	if c.NewTimeoutStrategy == nil {
 		c2.NewTimeoutStrategy = DefaultTimeoutStrategy
 	}
+	if c.NewReputation == nil {
+		c2.NewReputation = DefaultReputation
+	}
+	if c.Observer == nil {
+		c2.Observer = NoopObserver{}
+	}
+	if c.Clock == nil {
+		c2.Clock = RealClock()
+	}
 	if c.Logger == nil {
 		c2.Logger = DefaultLogger
 	}
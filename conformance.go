@@ -0,0 +1,201 @@
+package handel
+
+import (
+	"fmt"
+)
+
+// ConformanceSizes are the registry sizes CheckPartitioner uses when none are
+// given: all sizes up to 33 and a few larger ones around powers of two.
+var ConformanceSizes = append(sizesUpTo(33), 63, 64, 65, 100)
+
+// CheckPartitioner checks that the partitioners returned by the given
+// constructor, as given in the Config, respect the invariants Handel relies
+// on. For each of the given registry sizes, or ConformanceSizes if none is
+// given, it builds the partitioner of every node and checks that:
+//   - level 0 only contains the node itself, and the other levels are sorted
+//     and not greater than MaxLevel
+//   - the levels are disjoint and their union plus the node itself is the
+//     whole registry
+//   - Size agrees with IdentitiesAt
+//   - IndexAtLevel is the inverse of IdentitiesAt and rejects IDs outside the
+//     level
+//   - the bitset produced by Combine by a peer of a level is laid out as the
+//     node expects it for this level, i.e. as IndexAtLevel or, for a
+//     GroupPartitioner, as GroupAt and IndexAtLevel indicate
+//   - the bitset produced by CombineFull is indexed by registry index
+//
+// It returns an error describing the first violation found. It is meant to be
+// called from the tests of custom Partitioner implementations.
+func CheckPartitioner(newPartitioner func(int32, Registry, Logger) Partitioner, sizes ...int) error {
+	if len(sizes) == 0 {
+		sizes = ConformanceSizes
+	}
+	for _, n := range sizes {
+		ids := make([]Identity, n)
+		for i := range ids {
+			ids[i] = NewStaticIdentity(int32(i), fmt.Sprintf("conformance-%d", i), nil)
+		}
+		reg := NewArrayRegistry(ids)
+		parts := make([]Partitioner, n)
+		for i := range parts {
+			parts[i] = newPartitioner(int32(i), reg, noopLogger{})
+		}
+		for i := range parts {
+			if err := checkPartitionerLevels(parts, i); err != nil {
+				return fmt.Errorf("handel: partitioner of node %d/%d: %s", i, n, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPartitionerLevels checks the levels of the partitioner of the node i
+// and their consistency with the partitioners of its peers.
+func checkPartitionerLevels(parts []Partitioner, i int) error {
+	part := parts[i]
+	self, err := part.IdentitiesAt(0)
+	if err != nil {
+		return fmt.Errorf("level 0: %s", err)
+	}
+	if len(self) != 1 || self[0].ID() != int32(i) {
+		return fmt.Errorf("level 0 is not the node itself: %v", self)
+	}
+	seen := map[int32]bool{int32(i): true}
+	prev := 0
+	for _, level := range part.Levels() {
+		if level <= prev || level > part.MaxLevel() {
+			return fmt.Errorf("level %d out of order or above max level %d", level, part.MaxLevel())
+		}
+		prev = level
+		ids, err := part.IdentitiesAt(level)
+		if err != nil {
+			return fmt.Errorf("level %d: %s", level, err)
+		}
+		if len(ids) == 0 || len(ids) != part.Size(level) {
+			return fmt.Errorf("level %d: size %d for %d identities", level, part.Size(level), len(ids))
+		}
+		for idx, id := range ids {
+			if seen[id.ID()] {
+				return fmt.Errorf("level %d: identity %d in several levels", level, id.ID())
+			}
+			seen[id.ID()] = true
+			res, err := part.IndexAtLevel(id.ID(), level)
+			if err != nil || res != idx {
+				return fmt.Errorf("level %d: index of identity %d is %d, expected %d (%v)", level, id.ID(), res, idx, err)
+			}
+		}
+		if _, err := part.IndexAtLevel(int32(i), level); err == nil {
+			return fmt.Errorf("level %d: index found for the node itself", level)
+		}
+		for _, id := range ids {
+			if err := checkCombine(part, parts[id.ID()], id.ID(), level); err != nil {
+				return fmt.Errorf("level %d: combine of peer %d: %s", level, id.ID(), err)
+			}
+		}
+	}
+	if len(seen) != len(parts) {
+		return fmt.Errorf("levels cover %d identities out of %d", len(seen), len(parts))
+	}
+	return nil
+}
+
+// checkCombine checks that the signatures combined by the peer for the given
+// level are laid out as the receiver expects. Each round of the check sets a
+// single contribution in each level of the peer below the given level.
+func checkCombine(receiver, peer Partitioner, peerID int32, level int) error {
+	var lowers []int
+	levels := make(map[int][]Identity)
+	for _, lvl := range append([]int{0}, peer.Levels()...) {
+		if lvl >= level {
+			break
+		}
+		ids, err := peer.IdentitiesAt(lvl)
+		if err != nil {
+			return err
+		}
+		lowers = append(lowers, lvl)
+		levels[lvl] = ids
+	}
+	offset, size := 0, receiver.Size(level)
+	if gp, ok := receiver.(GroupPartitioner); ok {
+		var err error
+		offset, size, err = gp.GroupAt(peerID, level)
+		if err != nil {
+			return err
+		}
+	}
+	for pos := 0; ; pos++ {
+		var sigs []*incomingSig
+		var expected []int32
+		for _, lvl := range lowers {
+			ids := levels[lvl]
+			if pos >= len(ids) {
+				continue
+			}
+			bs := NewWilffBitset(len(ids))
+			bs.Set(pos, true)
+			expected = append(expected, ids[pos].ID())
+			sigs = append(sigs, &incomingSig{
+				origin: peerID,
+				level:  byte(lvl),
+				ms:     &MultiSignature{BitSet: bs, Signature: conformanceSig{}},
+			})
+		}
+		if len(sigs) == 0 {
+			return nil
+		}
+		ms := peer.Combine(sigs, level, NewWilffBitset)
+		if ms == nil || ms.BitSet.BitLength() != size {
+			return fmt.Errorf("combined bitset does not have the size %d of the level", size)
+		}
+		if ms.BitSet.Cardinality() != len(expected) {
+			return fmt.Errorf("combined bitset has %d contributions, expected %d", ms.BitSet.Cardinality(), len(expected))
+		}
+		for _, id := range expected {
+			idx, err := receiver.IndexAtLevel(id, level)
+			if err != nil {
+				return err
+			}
+			if idx < offset || !ms.BitSet.Get(idx-offset) {
+				return fmt.Errorf("contribution of %d not at its index %d", id, idx)
+			}
+		}
+
+		full := peer.CombineFull(sigs, NewWilffBitset)
+		if full == nil || full.BitSet.Cardinality() != len(expected) {
+			return fmt.Errorf("full bitset does not have the %d contributions", len(expected))
+		}
+		for _, id := range expected {
+			if !full.BitSet.Get(int(id)) {
+				return fmt.Errorf("contribution of %d not at its registry index in the full bitset", id)
+			}
+		}
+	}
+}
+
+// sizesUpTo returns the sizes from 1 to n included.
+func sizesUpTo(n int) []int {
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = i + 1
+	}
+	return sizes
+}
+
+// conformanceSig is the Signature combined by CheckPartitioner. It carries no
+// data since only the bitsets are checked.
+type conformanceSig struct{}
+
+func (conformanceSig) MarshalBinary() ([]byte, error) { return nil, nil }
+func (conformanceSig) UnmarshalBinary([]byte) error   { return nil }
+func (c conformanceSig) Combine(Signature) Signature  { return c }
+
+// noopLogger is a Logger discarding all statements. CheckPartitioner uses it
+// since rejecting IDs outside a level is expected to log warnings.
+type noopLogger struct{}
+
+func (noopLogger) Info(kv ...interface{})          {}
+func (noopLogger) Debug(kv ...interface{})         {}
+func (noopLogger) Warn(kv ...interface{})          {}
+func (noopLogger) Error(kv ...interface{})         {}
+func (n noopLogger) With(kv ...interface{}) Logger { return n }
@@ -0,0 +1,60 @@
+package handel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPartitioner(t *testing.T) {
+	coords := func(n int) Coordinates {
+		c := make(Coordinates, n)
+		for i := range c {
+			c[i] = Coordinate{Vec: []float64{float64(i % 7), float64(i / 7)}}
+		}
+		return c
+	}
+	var tests = []struct {
+		name string
+		new  func(int32, Registry, Logger) Partitioner
+	}{
+		{"binomial", NewBinPartitioner},
+		{"random", RandomBinPartitionerConstructor([]byte("conformance"))},
+		{"latency", func(id int32, reg Registry, logger Logger) Partitioner {
+			part, err := NewLatencyPartitioner(id, reg, coords(reg.Size()), logger)
+			if err != nil {
+				panic(err)
+			}
+			return part
+		}},
+		{"kary-2", KaryPartitionerConstructor(2)},
+		{"kary-3", KaryPartitionerConstructor(3)},
+		{"kary-4", KaryPartitionerConstructor(4)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, CheckPartitioner(test.new))
+		})
+	}
+}
+
+// reversedPartitioner breaks the layout of the bitsets of the levels.
+type reversedPartitioner struct {
+	Partitioner
+}
+
+func (r *reversedPartitioner) IndexAtLevel(globalID int32, level int) (int, error) {
+	idx, err := r.Partitioner.IndexAtLevel(globalID, level)
+	if err != nil {
+		return idx, err
+	}
+	return r.Size(level) - 1 - idx, nil
+}
+
+func TestCheckPartitionerViolation(t *testing.T) {
+	reversed := func(id int32, reg Registry, logger Logger) Partitioner {
+		return &reversedPartitioner{NewBinPartitioner(id, reg, logger)}
+	}
+	require.Error(t, CheckPartitioner(reversed, 4))
+	require.NoError(t, CheckPartitioner(reversed, 1, 2))
+}
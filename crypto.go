@@ -41,6 +41,17 @@ type Constructor interface {
 	PublicKey() PublicKey
 }
 
+// BatchVerifier is an optional interface a Constructor can implement to verify
+// multiple signatures over the same message at once, faster than verifying
+// each of them separately. Handel uses it to verify its queue of pending
+// signatures in batches, see Config.VerifyBatchSize.
+type BatchVerifier interface {
+	// VerifyBatch returns nil if each signature is valid with respect to the
+	// public key at the same index and the message. It returns an error if at
+	// least one of them is invalid, without telling which one.
+	VerifyBatch(msg []byte, pubs []PublicKey, sigs []Signature) error
+}
+
 // Signature holds methods to pass from/to a binary representation and to
 // combine signatures together
 type Signature interface {
@@ -23,6 +23,7 @@ require (
 	github.com/multiformats/go-multiaddr v0.0.4
 	github.com/pkg/sftp v1.10.0
 	github.com/stretchr/testify v1.3.0
+	github.com/supranational/blst v0.3.16
 	github.com/whyrusleeping/go-logging v0.0.0-20170515211332-0457bb6b88fc
 	github.com/willf/bitset v1.1.10
 	go.dedis.ch/onet/v3 v3.0.2
@@ -2,6 +2,7 @@ package handel
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -34,19 +35,44 @@ type Handel struct {
 	store SignatureStore
 	// processing of signature - verification strategy
 	proc signatureProcessing
+	// reputation of the peers, to drop or deprioritize misbehaving ones
+	rep Reputation
+	// notified of the progress of the round
+	obs Observer
 	// all actors registered that acts on a new signature
 	actors []actor
 	// best final signature,i.e. at the last level, seen so far
 	best *MultiSignature
 	// channel to exposes multi-signatures to the user
 	out chan MultiSignature
+	// indicating whether the output channel is closed, i.e. handel completed
+	// or finished
+	outClosed bool
+	// timers completing and stopping handel according to the completion
+	// policy
+	completeTimer Timer
+	deadlineTimer Timer
+	lingerTimer   Timer
 	// indicating whether handel is finished or not
 	done bool
+	// indicating whether handel has been started or not
+	started bool
+	// closed when handel is finished
+	finished chan struct{}
+	// reason why handel finished, nil while running
+	err error
+	// cancels the context of the sub routines
+	cancel context.CancelFunc
 	// constant threshold of contributions required in a ms to be considered
 	// valid
 	threshold int
+	// minimum summed weight of contributions required in a ms to be
+	// considered valid, only used in weighted mode
+	weightThreshold int64
+	// weight of each node of the registry, nil if not in weighted mode
+	weights []int64
 	// ticker for the periodic update
-	ticker *time.Ticker
+	ticker Ticker
 	// all the levels
 	levels map[int]*level
 	// ids of the level in order as returned by the partitioner
@@ -55,6 +81,9 @@ type Handel struct {
 	startTime time.Time
 	// the timeout strategy used by handel
 	timeout TimeoutStrategy
+	// the timeout strategy if it adapts to the progress of the levels - may be
+	// nil
+	progress ProgressListener
 	// the logger used by this Handel
 	log Logger
 	// minimal stats about Handel
@@ -92,10 +121,12 @@ func NewHandel(n Network, r Registry, id Identity, c Constructor,
 		msg:         msg,
 		sig:         s,
 		out:         make(chan MultiSignature, 10000),
-		ticker:      time.NewTicker(config.UpdatePeriod),
+		finished:    make(chan struct{}),
 		log:         log,
 		levels:      createLevels(config, part),
 		ids:         part.Levels(),
+		rep:         config.NewReputation(r),
+		obs:         config.Observer,
 	}
 	h.actors = []actor{
 		actorFunc(h.checkCompletedLevel),
@@ -103,7 +134,28 @@ func NewHandel(n Network, r Registry, id Identity, c Constructor,
 	}
 
 	h.threshold = h.c.Contributions
-	h.store = newStore(part, h.c.NewBitSet, c)
+	var st *store
+	if h.c.Weighted {
+		h.weights = make([]int64, r.Size())
+		var total int64
+		for i := range h.weights {
+			if id, ok := r.Identity(i); ok {
+				h.weights[i] = IdentityWeight(r, id)
+				total += h.weights[i]
+			}
+		}
+		h.weightThreshold = h.c.ContributionsWeight
+		if h.weightThreshold == 0 {
+			h.weightThreshold = PercentageToWeight(DefaultContributionsPerc, total)
+		}
+		st = newWeightedStore(part, h.c.NewBitSet, c, r)
+	} else {
+		st = newStore(part, h.c.NewBitSet, c)
+	}
+	h.store = st
+	if h.c.Candidates > 0 {
+		h.store = newCandidateStore(st, h.c.Candidates)
+	}
 
 	// We need to add our own sig at level 0
 	ind := &incomingSig{
@@ -114,10 +166,16 @@ func NewHandel(n Network, r Registry, id Identity, c Constructor,
 		mappedIndex: 0,
 	}
 	h.store.Store(ind) // Our own sig is at level 0.
+	if config.Snapshot != nil {
+		if err := h.restore(config.Snapshot); err != nil {
+			h.log.Error("snapshot", err)
+		}
+	}
 	evaluator := h.c.NewEvaluatorStrategy(h.store, h)
-	h.proc = newEvaluatorProcessing(part, c, msg, config.UnsafeSleepTimeOnSigVerify, evaluator, h.log)
+	h.proc = newEvaluatorProcessing(part, c, config.KeyCache, msg, config.UnsafeSleepTimeOnSigVerify, config.VerifyWorkers, config.VerifyBatchSize, config.MaxQueueSize, config.QueuePolicy, evaluator, h.rep, h.obs, config.Clock, h.log)
 	h.net.RegisterListener(h)
 	h.timeout = h.c.NewTimeoutStrategy(h, h.ids)
+	h.progress, _ = h.timeout.(ProgressListener)
 	return h
 }
 
@@ -133,11 +191,19 @@ func (h *Handel) NewPacket(p *Packet) {
 	}
 	if err := h.validatePacket(p); err != nil {
 		h.log.Warn("invalid_packet", err)
+		h.obs.OnPacketDropped(p, InvalidPacket, err)
+		return
+	}
+	if h.rep.Action(p.Origin) == DropPacket {
+		h.log.Debug("dropped_from", p.Origin)
+		h.obs.OnPacketDropped(p, BadReputation, nil)
 		return
 	}
 	ms, ind, err := h.parseSignatures(p)
 	if err != nil {
 		h.log.Warn("invalid_packet - multisig", err)
+		h.rep.Penalize(p.Origin, MalformedPacket)
+		h.obs.OnPacketDropped(p, InvalidSignatures, err)
 		return
 	} else if !h.getLevel(p.Level).rcvCompleted {
 		// sends it to processing
@@ -151,36 +217,201 @@ func (h *Handel) NewPacket(p *Packet) {
 	}
 }
 
+// ErrCompleted is returned by Err when Handel has been stopped after having
+// output a final signature reaching the threshold of contributions.
+var ErrCompleted = errors.New("handel: round completed")
+
+// ErrStopped is returned by Err when Handel has been stopped before having
+// output any final signature reaching the threshold of contributions.
+var ErrStopped = errors.New("handel: round stopped")
+
 // Start the Handel protocol by sending signatures to peers in the first level,
-// and by starting relevant sub-routines.
+// and by starting relevant sub-routines. It is equivalent to starting Handel
+// with a context that is never cancelled: only Stop ends the round.
 func (h *Handel) Start() {
+	h.start(context.Background())
+}
+
+// Run starts the Handel protocol like Start and blocks until the round is
+// finished, either because Stop has been called or because the given context
+// has been cancelled or has expired. All sub-routines are stopped when the
+// context is done. It returns the reason why the round finished, as Err does.
+// If Handel has already been started, Run still stops it when the given
+// context is done.
+func (h *Handel) Run(ctx context.Context) error {
+	h.start(ctx)
+	select {
+	case <-h.Done():
+	case <-ctx.Done():
+		h.Lock()
+		h.stop(ctx.Err())
+		h.Unlock()
+	}
+	return h.Err()
+}
+
+func (h *Handel) start(ctx context.Context) {
 	h.Lock()
 	defer h.Unlock()
-	h.startTime = time.Now()
+	if h.started || h.done {
+		return
+	}
+	h.started = true
+	ctx, h.cancel = context.WithCancel(ctx)
+	h.startTime = h.c.Clock.Now()
+	h.ticker = h.c.Clock.NewTicker(h.c.UpdatePeriod)
+	for _, id := range h.ids {
+		if h.levels[id].started() {
+			// levels started from the beginning
+			h.obs.OnLevelStart(id)
+		}
+	}
+	if h.c.Completion != nil && h.c.Completion.Deadline() > 0 {
+		h.deadlineTimer = h.c.Clock.AfterFunc(h.c.Completion.Deadline(), h.complete)
+	}
 	go h.proc.Start()
 	go h.rangeOnVerified()
 	go h.timeout.Start()
-	go h.periodicLoop()
+	go h.periodicLoop(ctx, h.ticker.Chan())
+	go h.watchContext(ctx)
+	if h.c.Snapshot != nil {
+		// the restored signatures may already reach the threshold
+		h.checkFinalSignature(nil)
+	}
 }
 
-// periodicLoop simply calls the periodic update each period of time.
-func (h *Handel) periodicLoop() {
-	for range h.ticker.C {
-		h.periodicUpdate()
+// periodicLoop simply calls the periodic update each period of time until the
+// context is done.
+func (h *Handel) periodicLoop(ctx context.Context, c <-chan time.Time) {
+	for {
+		select {
+		case <-c:
+			h.periodicUpdate()
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-// Stop the Handel protocol and all sub routines
+// watchContext stops Handel as soon as the context is done. If Handel has
+// been stopped by other means, the context is cancelled by stop and this
+// routine exits without further effect.
+func (h *Handel) watchContext(ctx context.Context) {
+	<-ctx.Done()
+	h.Lock()
+	defer h.Unlock()
+	h.stop(ctx.Err())
+}
+
+// Stop the Handel protocol and all sub routines. It is safe to call Stop
+// multiple times.
 func (h *Handel) Stop() {
 	h.Lock()
 	defer h.Unlock()
-	h.ticker.Stop()
+	if h.best != nil {
+		h.stop(ErrCompleted)
+	} else {
+		h.stop(ErrStopped)
+	}
+}
+
+// stop is the "unlocked" version of Stop. The given error is the reason why
+// Handel is stopped. Only the first call has an effect.
+func (h *Handel) stop(reason error) {
+	if h.done {
+		return
+	}
+	h.done = true
+	h.err = reason
+	if h.cancel != nil {
+		h.cancel()
+	}
+	if h.ticker != nil {
+		h.ticker.Stop()
+	}
 	h.timeout.Stop()
 	h.proc.Stop()
-	h.done = true
+	for _, t := range []Timer{h.completeTimer, h.deadlineTimer, h.lingerTimer} {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	h.closeOutput()
+	close(h.finished)
+}
+
+// complete completes the Handel round according to the completion policy: it
+// closes the output channel and stops Handel after the linger period.
+func (h *Handel) complete() {
+	h.Lock()
+	defer h.Unlock()
+	h.unsafeComplete()
+}
+
+// unsafeComplete is the "unlocked" version of complete.
+func (h *Handel) unsafeComplete() {
+	if h.done || h.outClosed {
+		return
+	}
+	h.closeOutput()
+	reason := ErrCompleted
+	if h.best == nil {
+		reason = ErrDeadline
+	}
+	linger := h.c.Completion.Linger()
+	if linger <= 0 {
+		h.stop(reason)
+		return
+	}
+	h.log.Debug("linger", linger)
+	h.lingerTimer = h.c.Clock.AfterFunc(linger, func() {
+		h.Lock()
+		defer h.Unlock()
+		h.stop(reason)
+	})
+}
+
+// closeOutput closes the output channel if not already closed.
+func (h *Handel) closeOutput() {
+	if h.outClosed {
+		return
+	}
+	h.outClosed = true
 	close(h.out)
 }
 
+// checkCompletion asks the completion policy, if any, whether the given new
+// final signature completes the round.
+func (h *Handel) checkCompletion(ms *MultiSignature) {
+	if h.c.Completion == nil {
+		return
+	}
+	delay := h.c.Completion.Complete(ms, h.reg.Size())
+	switch {
+	case delay == 0:
+		h.unsafeComplete()
+	case delay > 0 && h.completeTimer == nil:
+		h.completeTimer = h.c.Clock.AfterFunc(delay, h.complete)
+	}
+}
+
+// Done returns a channel that is closed when the Handel round is finished,
+// i.e. after Stop has been called or after the context given to Run is done.
+func (h *Handel) Done() <-chan struct{} {
+	return h.finished
+}
+
+// Err returns nil if the Handel round is not finished yet. Otherwise, it
+// returns ErrCompleted if the round finished after having output a final
+// signature over the threshold, ErrStopped if it has been stopped before,
+// context.DeadlineExceeded if the context given to Run expired or
+// context.Canceled if that context was cancelled.
+func (h *Handel) Err() error {
+	h.Lock()
+	defer h.Unlock()
+	return h.err
+}
+
 // periodicUpdate sends the best multi-signature (potentially ind. sig.) for
 // each started level.
 func (h *Handel) periodicUpdate() {
@@ -208,6 +439,7 @@ func (h *Handel) unsafeStartLevel(lvl *level) {
 		return
 	}
 	lvl.setStarted()
+	h.obs.OnLevelStart(lvl.id)
 	h.sendUpdate(lvl, h.c.UpdateCount)
 }
 
@@ -224,9 +456,16 @@ func (h *Handel) sendUpdate(l *level, count int) {
 	h.sendTo(l.id, newNodes, ms, sig)
 }
 
+// Reputation returns the Reputation used by Handel to keep track of the
+// misbehaving peers.
+func (h *Handel) Reputation() Reputation {
+	return h.rep
+}
+
 // FinalSignatures returns the channel over which final multi-signatures
 // are sent over. These multi-signatures contain at least a threshold of
-// contributions, as defined in the config.
+// contributions, as defined in the config. The channel is closed once Handel
+// completes according to its completion policy, or is stopped.
 func (h *Handel) FinalSignatures() chan MultiSignature {
 	return h.out
 }
@@ -238,8 +477,15 @@ func (h *Handel) FinalSignatures() chan MultiSignature {
 //     a thread safe manner, global lock is held during the call to actors.
 func (h *Handel) rangeOnVerified() {
 	for v := range h.proc.Verified() {
-		h.store.Store(&v)
+		if ms := h.store.Store(&v); ms != nil {
+			h.obs.OnNewBest(int(v.level), ms)
+			h.notifyProgress(int(v.level), ms)
+		}
 		h.Lock()
+		if h.done {
+			h.Unlock()
+			continue
+		}
 		for _, actor := range h.actors {
 			actor.OnVerifiedSignature(&v)
 		}
@@ -247,6 +493,24 @@ func (h *Handel) rangeOnVerified() {
 	}
 }
 
+// notifyProgress feeds the new best multi-signature of the given level to the
+// timeout strategy if it is a ProgressListener. It must be called without
+// holding the lock since the strategy may start levels.
+func (h *Handel) notifyProgress(level int, ms *MultiSignature) {
+	if h.progress == nil || level == 0 {
+		return
+	}
+	p := LevelProgress{
+		Level:    level,
+		Received: ms.Cardinality(),
+		Size:     h.Partitioner.Size(level),
+	}
+	if l, ok := h.proc.(loadReporter); ok {
+		p.Pending, p.VerifyTime = l.load()
+	}
+	h.progress.OnLevelProgress(p)
+}
+
 // actor is an interface that takes a new verified signature and acts on it
 // according to its own rule. It can be checking if it passes to a next level,
 // checking if the protocol is finished, checking if a signature completes
@@ -271,16 +535,18 @@ func (a actorFunc) OnVerifiedSignature(s *incomingSig) {
 func (h *Handel) checkFinalSignature(s *incomingSig) {
 	sig := h.store.FullSignature()
 
-	if sig.BitSet.Cardinality() < h.threshold {
+	if !h.reachesThreshold(sig) {
 		return
 	}
 	newBest := func(ms *MultiSignature) {
-		if h.done {
+		if h.done || h.outClosed {
 			return
 		}
 		h.best = ms
 		h.log.Info("new_sig", fmt.Sprintf("%d/%d/%d", ms.Cardinality(), h.threshold, h.reg.Size()))
+		h.obs.OnFinalSignature(ms)
 		h.out <- *h.best
+		h.checkCompletion(ms)
 	}
 
 	if h.best == nil {
@@ -288,6 +554,13 @@ func (h *Handel) checkFinalSignature(s *incomingSig) {
 		return
 	}
 
+	if h.weights != nil {
+		if h.weight(sig.BitSet) > h.weight(h.best.BitSet) {
+			newBest(sig)
+		}
+		return
+	}
+
 	newCard := sig.Cardinality()
 	local := h.best.Cardinality()
 	if newCard > local {
@@ -295,6 +568,25 @@ func (h *Handel) checkFinalSignature(s *incomingSig) {
 	}
 }
 
+// reachesThreshold returns true if the given full multi-signature contains
+// enough contributions, or enough weight in weighted mode.
+func (h *Handel) reachesThreshold(ms *MultiSignature) bool {
+	if h.weights != nil {
+		return h.weight(ms.BitSet) >= h.weightThreshold
+	}
+	return ms.BitSet.Cardinality() >= h.threshold
+}
+
+// weight returns the summed weight of the nodes set in the given bitset
+// indexed over the whole registry.
+func (h *Handel) weight(bs BitSet) int64 {
+	var total int64
+	for i, ok := bs.NextSet(0); ok; i, ok = bs.NextSet(i + 1) {
+		total += h.weights[i]
+	}
+	return total
+}
+
 // checkCompletedLevels checks if higher levels may be completed by the given
 // signature. For each of those, it sends the update to the corresponding peers
 // in a fast path fashion.
@@ -312,6 +604,7 @@ func (h *Handel) checkCompletedLevel(s *incomingSig) {
 	if sp.Cardinality() == len(lvl.nodes) {
 		h.log.Debug("level_complete", s.level)
 		lvl.rcvCompleted = true
+		h.obs.OnLevelComplete(int(s.level))
 	}
 
 	// The sending phase: for all upper levels we may have completed the level.
@@ -321,7 +614,12 @@ func (h *Handel) checkCompletedLevel(s *incomingSig) {
 			continue
 		}
 		ms := h.store.Combined(byte(id) - 1)
+		started := lvl.started()
 		if ms != nil && lvl.updateSigToSend(ms) {
+			if !started {
+				// the level starts because the lower levels are complete
+				h.obs.OnLevelStart(id)
+			}
 			h.sendUpdate(lvl, h.c.FastPath)
 		}
 	}
@@ -396,7 +694,16 @@ func (h *Handel) parseSignatures(p *Packet) (ms *incomingSig, ind *incomingSig,
 
 	// level is already check before
 	lvl, _ := h.levels[int(p.Level)]
-	if m.BitLength() != len(lvl.nodes) {
+	offset, size := 0, len(lvl.nodes)
+	gp, grouped := h.Partitioner.(GroupPartitioner)
+	if grouped {
+		// the multi-signature only covers the group of the sender
+		offset, size, err = gp.GroupAt(p.Origin, int(p.Level))
+		if err != nil {
+			return
+		}
+	}
+	if m.BitLength() != size {
 		err = errors.New("invalid bitset's size for given level")
 		return
 	}
@@ -404,6 +711,13 @@ func (h *Handel) parseSignatures(p *Packet) (ms *incomingSig, ind *incomingSig,
 		err = errors.New("no signature in the bitset")
 		return
 	}
+	if size != len(lvl.nodes) {
+		bs := h.c.NewBitSet(len(lvl.nodes))
+		for i, ok := m.BitSet.NextSet(0); ok; i, ok = m.BitSet.NextSet(i + 1) {
+			bs.Set(offset+i, true)
+		}
+		m.BitSet = bs
+	}
 	ms = &incomingSig{
 		origin: p.Origin,
 		level:  p.Level,
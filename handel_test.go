@@ -2,6 +2,7 @@ package handel
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"fmt"
 	"sync"
@@ -126,6 +127,27 @@ func testHandelTestNetwork(t *testing.T, tests []handelTest) {
 	}
 }
 
+func TestHandelVerifyWorkers(t *testing.T) {
+	n := 67
+	config := DefaultConfig(n)
+	config.VerifyWorkers = 4
+	config.NewTimeoutStrategy = newInfiniteTimeout
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	test.Start()
+	defer test.Stop()
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(10 * time.Second):
+		t.Fatal("handel with verification workers did not finish")
+	}
+}
+
 func TestHandelWholeThing(t *testing.T) {
 	//t.Skip()
 	n := 32
@@ -208,6 +230,45 @@ func TestHandelWholeThing(t *testing.T) {
 	require.True(t, counter >= n)
 }
 
+func TestHandelContextLifecycle(t *testing.T) {
+	n := 8
+	_, handels := FakeSetup(n)
+	defer CloseHandels(handels)
+
+	// nobody else is running so the round can not complete
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, handels[0].Run(ctx))
+
+	h := handels[1]
+	require.Nil(t, h.Err())
+	ctx, cancel = context.WithCancel(context.Background())
+	go h.Run(ctx)
+	cancel()
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("handel not stopped after cancellation")
+	}
+	require.Equal(t, context.Canceled, h.Err())
+	_, open := <-h.FinalSignatures()
+	require.False(t, open)
+	// stopping again is a no-op
+	h.Stop()
+	require.Equal(t, context.Canceled, h.Err())
+
+	h = handels[2]
+	h.Stop()
+	require.Equal(t, ErrStopped, h.Err())
+
+	// the context of Run applies to an already started round
+	h = handels[3]
+	h.Start()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, h.Run(ctx))
+}
+
 func TestHandelCheckCompletedLevel(t *testing.T) {
 	n := 8
 	_, handels := FakeSetup(n)
@@ -332,6 +393,38 @@ func TestHandelCheckFinalSignature(t *testing.T) {
 	}
 }
 
+func TestHandelWeightedThreshold(t *testing.T) {
+	n := 8
+	weights := []int64{100, 1, 1, 1, 1, 1, 1, 1}
+	reg := &fakeWeightedRegistry{FakeRegistry(n), weights}
+	id, _ := reg.Identity(1)
+	net := &TestNetwork{1, make([]Network, n), nil}
+	conf := &Config{Weighted: true}
+	h := NewHandel(net, reg, id, new(fakeCons), msg, &fakeSig{true}, conf)
+	defer h.Stop()
+	require.Equal(t, PercentageToWeight(DefaultContributionsPerc, 107), h.weightThreshold)
+
+	// the two first nodes hold the majority of the weight
+	h.store.Store(fullIncomingSig(1))
+	h.checkFinalSignature(nil)
+	select {
+	case ms := <-h.FinalSignatures():
+		require.Equal(t, 2, ms.Cardinality())
+	case <-time.After(20 * time.Millisecond):
+		t.Fatal("no final signature")
+	}
+
+	// more weight gives a better final signature
+	h.store.Store(fullIncomingSig(2))
+	h.checkFinalSignature(nil)
+	select {
+	case ms := <-h.FinalSignatures():
+		require.Equal(t, 4, ms.Cardinality())
+	case <-time.After(20 * time.Millisecond):
+		t.Fatal("no final signature")
+	}
+}
+
 func TestHandelParsePacket(t *testing.T) {
 	n := 16
 	registry := FakeRegistry(n)
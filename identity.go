@@ -30,6 +30,50 @@ type Registry interface {
 	Identities(from, to int) ([]Identity, bool)
 }
 
+// WeightedIdentity is an Identity carrying a weight, for example the stake of
+// the node. Handel sums the weights of the contributions of a multi-signature
+// when Config.Weighted is set.
+type WeightedIdentity interface {
+	Identity
+	// Weight returns the weight of the node. It must not be negative.
+	Weight() int64
+}
+
+// WeightedRegistry is a Registry that knows the weight of each node. Its
+// weights take precedence over the ones of the identities.
+type WeightedRegistry interface {
+	Registry
+	// Weight returns the weight of the node at this index in the registry.
+	Weight(int) int64
+}
+
+// IdentityWeight returns the weight of the given identity, as given by the
+// registry if it is a WeightedRegistry, or by the identity if it is a
+// WeightedIdentity. Otherwise, the identity weighs 1.
+func IdentityWeight(reg Registry, id Identity) int64 {
+	if wr, ok := reg.(WeightedRegistry); ok {
+		return wr.Weight(int(id.ID()))
+	}
+	if wi, ok := id.(WeightedIdentity); ok {
+		return wi.Weight()
+	}
+	return 1
+}
+
+// TotalWeight returns the sum of the weights of all the identities of the
+// registry. See IdentityWeight.
+func TotalWeight(reg Registry) int64 {
+	var total int64
+	for i := 0; i < reg.Size(); i++ {
+		id, ok := reg.Identity(i)
+		if !ok {
+			continue
+		}
+		total += IdentityWeight(reg, id)
+	}
+	return total
+}
+
 // fixedIdentity is an Identity using fixed in-memory data.
 type fixedIdentity struct {
 	id   int32
@@ -46,6 +90,29 @@ func NewStaticIdentity(id int32, addr string, p PublicKey) Identity {
 	}
 }
 
+// weightedIdentity is a fixedIdentity carrying a weight.
+type weightedIdentity struct {
+	*fixedIdentity
+	weight int64
+}
+
+// NewStaticWeightedIdentity returns a WeightedIdentity fixed by these
+// parameters
+func NewStaticWeightedIdentity(id int32, addr string, p PublicKey, weight int64) WeightedIdentity {
+	return &weightedIdentity{
+		fixedIdentity: &fixedIdentity{
+			id:   id,
+			addr: addr,
+			p:    p,
+		},
+		weight: weight,
+	}
+}
+
+func (w *weightedIdentity) Weight() int64 {
+	return w.weight
+}
+
 func (s *fixedIdentity) Address() string {
 	return s.addr
 }
@@ -54,3 +54,36 @@ func testRegistryTests(t *testing.T, tests []registryTest) {
 		}
 	}
 }
+
+// fakeWeightedRegistry is a Registry giving the weight of each node
+type fakeWeightedRegistry struct {
+	Registry
+	weights []int64
+}
+
+func (f *fakeWeightedRegistry) Weight(i int) int64 {
+	return f.weights[i]
+}
+
+func TestIdentityWeight(t *testing.T) {
+	ids := []Identity{
+		NewStaticWeightedIdentity(0, "", &fakePublic{true}, 10),
+		NewStaticWeightedIdentity(1, "", &fakePublic{true}, 5),
+		NewStaticIdentity(2, "", &fakePublic{true}),
+	}
+	reg := NewArrayRegistry(ids)
+	require.Equal(t, int64(10), IdentityWeight(reg, ids[0]))
+	require.Equal(t, int64(1), IdentityWeight(reg, ids[2]))
+	require.Equal(t, int64(16), TotalWeight(reg))
+
+	// the weights of the registry take precedence
+	wreg := &fakeWeightedRegistry{reg, []int64{1, 2, 3}}
+	require.Equal(t, int64(1), IdentityWeight(wreg, ids[0]))
+	require.Equal(t, int64(3), IdentityWeight(wreg, ids[2]))
+	require.Equal(t, int64(6), TotalWeight(wreg))
+
+	require.Equal(t, int64(0), PercentageToWeight(51, 0))
+	require.Equal(t, int64(9), PercentageToWeight(51, 16))
+	require.Equal(t, int64(51), PercentageToWeight(51, 100))
+	require.Equal(t, int64(67), PercentageToWeight(66, 101))
+}
@@ -0,0 +1,237 @@
+package handel
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GroupPartitioner is implemented by the Partitioners whose levels are made of
+// multiple groups of peers. A peer only sends at a given level the
+// multi-signature of its own group: its bitset covers the group and not the
+// whole level. Handel places the received bitsets in the level using GroupAt,
+// and the store keeps the best multi-signature of each group.
+type GroupPartitioner interface {
+	Partitioner
+	// GroupAt returns the offset and the size, in the bitsets of the given
+	// level, of the group the given global ID belongs to.
+	GroupAt(globalID int32, level int) (offset int, size int, err error)
+}
+
+// karyPartitioner is a Partitioner using a k-ary tree instead of the binary
+// tree of the binomialPartitioner: the nodes are split in groups of k^level
+// consecutive IDs. At each level, a node contacts the k-1 groups of
+// k^(level-1) nodes that are siblings of its own group, so there are only
+// log_k(n) levels. The bitsets of a level cover the sibling groups in
+// increasing ID order.
+type karyPartitioner struct {
+	id     int
+	k      int
+	size   int
+	depth  int
+	reg    Registry
+	logger Logger
+}
+
+// NewKaryPartitioner returns a k-ary tree Partitioner using the given ID as
+// its anchor point in the ID list, and the given registry. The branching
+// factor k must be at least 2. With k = 2, the levels are the same as the
+// ones of the BinPartitioner.
+func NewKaryPartitioner(id int32, reg Registry, k int, logger Logger) Partitioner {
+	if k < 2 {
+		panic("handel: k-ary partitioner needs a branching factor of at least 2")
+	}
+	depth := 0
+	for width := 1; width < reg.Size(); width *= k {
+		depth++
+	}
+	return &karyPartitioner{
+		id:     int(id),
+		k:      k,
+		size:   reg.Size(),
+		depth:  depth,
+		reg:    reg,
+		logger: logger,
+	}
+}
+
+// KaryPartitionerConstructor returns the k-ary partitioner constructor as
+// required for the Config, using the given branching factor.
+func KaryPartitionerConstructor(k int) func(int32, Registry, Logger) Partitioner {
+	return func(id int32, reg Registry, logger Logger) Partitioner {
+		return NewKaryPartitioner(id, reg, k, logger)
+	}
+}
+
+func (c *karyPartitioner) MaxLevel() int {
+	return c.depth
+}
+
+func (c *karyPartitioner) Levels() []int {
+	var levels []int
+	for i := 1; i <= c.depth; i++ {
+		if _, err := c.ranges(i); err != nil {
+			continue
+		}
+		levels = append(levels, i)
+	}
+	return levels
+}
+
+func (c *karyPartitioner) Size(level int) int {
+	ranges, err := c.ranges(level)
+	if err != nil {
+		if err == errEmptyLevel {
+			return 0
+		}
+		panic(err)
+	}
+	size := 0
+	for _, r := range ranges {
+		size += r[1] - r[0]
+	}
+	return size
+}
+
+func (c *karyPartitioner) IdentitiesAt(level int) ([]Identity, error) {
+	ranges, err := c.ranges(level)
+	if err != nil {
+		return nil, err
+	}
+	var ids []Identity
+	for _, r := range ranges {
+		rids, ok := c.reg.Identities(r[0], r[1])
+		if !ok {
+			return nil, errors.New("handel: registry can't find ids in range")
+		}
+		ids = append(ids, rids...)
+	}
+	return ids, nil
+}
+
+func (c *karyPartitioner) IndexAtLevel(globalID int32, level int) (int, error) {
+	ranges, err := c.ranges(level)
+	if err != nil {
+		return 0, err
+	}
+	id := int(globalID)
+	offset := 0
+	for _, r := range ranges {
+		if id >= r[0] && id < r[1] {
+			return offset + id - r[0], nil
+		}
+		offset += r[1] - r[0]
+	}
+	err = fmt.Errorf("globalID outside level's range. id=%d, level=%d", id, level)
+	c.logger.Warn(err) // If it happens it's either a bug either an attack from a byzantine node
+	return 0, err
+}
+
+// GroupAt implements the GroupPartitioner interface.
+func (c *karyPartitioner) GroupAt(globalID int32, level int) (int, int, error) {
+	if level == 0 {
+		return 0, 1, nil
+	}
+	min, max := c.group(int(globalID), level-1)
+	offset, err := c.IndexAtLevel(int32(min), level)
+	if err != nil {
+		return 0, 0, err
+	}
+	return offset, max - min, nil
+}
+
+func (c *karyPartitioner) Combine(sigs []*incomingSig, level int, nbs func(int) BitSet) *MultiSignature {
+	if len(sigs) == 0 {
+		return nil
+	}
+	for _, s := range sigs {
+		if int(s.level) > level {
+			logf("invalid combination of signature / requested level")
+			return nil
+		}
+	}
+	// the signature sent at a level covers our own group at the level below
+	min, max := c.group(c.id, level-1)
+	return c.combineRange(sigs, min, max, nbs)
+}
+
+func (c *karyPartitioner) CombineFull(sigs []*incomingSig, nbs func(int) BitSet) *MultiSignature {
+	if len(sigs) == 0 {
+		return nil
+	}
+	return c.combineRange(sigs, 0, c.size, nbs)
+}
+
+// combineRange combines the signatures in a bitset covering the IDs in
+// [min,max[.
+func (c *karyPartitioner) combineRange(sigs []*incomingSig, min, max int, nbs func(int) BitSet) *MultiSignature {
+	bs := nbs(max - min)
+	var final Signature
+	for _, s := range sigs {
+		ranges, err := c.ranges(int(s.level))
+		if err != nil {
+			continue
+		}
+		if final == nil {
+			final = s.ms.Signature
+		} else {
+			final = final.Combine(s.ms.Signature)
+		}
+		idx := 0
+		for _, r := range ranges {
+			for id := r[0]; id < r[1]; id++ {
+				if id >= min && id < max && idx < s.ms.BitSet.BitLength() {
+					bs.Set(id-min, s.ms.BitSet.Get(idx))
+				}
+				idx++
+			}
+		}
+	}
+	if final == nil {
+		return nil
+	}
+	return &MultiSignature{BitSet: bs, Signature: final}
+}
+
+// group returns the range [min,max[ of the IDs of the group of k^level nodes
+// the given ID belongs to.
+func (c *karyPartitioner) group(id, level int) (min int, max int) {
+	if level >= c.depth {
+		return 0, c.size
+	}
+	width := 1
+	for i := 0; i < level; i++ {
+		width *= c.k
+	}
+	min = id / width * width
+	max = min + width
+	if max > c.size {
+		max = c.size
+	}
+	return min, max
+}
+
+// ranges returns the ranges [min,max[ of IDs composing the given level, in
+// increasing order: the group of our ID at this level minus our group at the
+// level below. It returns errEmptyLevel if the level is empty and an error if
+// the level is out of bound.
+func (c *karyPartitioner) ranges(level int) ([][2]int, error) {
+	if level < 0 || level > c.depth {
+		return nil, errors.New("handel: invalid level for computing candidate set")
+	}
+	if level == 0 {
+		return [][2]int{{c.id, c.id + 1}}, nil
+	}
+	min, max := c.group(c.id, level)
+	smin, smax := c.group(c.id, level-1)
+	var ranges [][2]int
+	if min < smin {
+		ranges = append(ranges, [2]int{min, smin})
+	}
+	if smax < max {
+		ranges = append(ranges, [2]int{smax, max})
+	}
+	if len(ranges) == 0 {
+		return nil, errEmptyLevel
+	}
+	return ranges, nil
+}
@@ -0,0 +1,161 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionerKaryBinary(t *testing.T) {
+	for _, n := range []int{2, 5, 8, 13, 16, 33} {
+		reg := FakeRegistry(n)
+		for id := 0; id < n; id++ {
+			kary := NewKaryPartitioner(int32(id), reg, 2, DefaultLogger)
+			bin := NewBinPartitioner(int32(id), reg, DefaultLogger)
+			require.Equal(t, bin.MaxLevel(), kary.MaxLevel())
+			require.Equal(t, bin.Levels(), kary.Levels())
+			for _, lvl := range bin.Levels() {
+				require.Equal(t, bin.Size(lvl), kary.Size(lvl))
+				exp, err := bin.IdentitiesAt(lvl)
+				require.NoError(t, err)
+				ids, err := kary.IdentitiesAt(lvl)
+				require.NoError(t, err)
+				require.Equal(t, exp, ids)
+			}
+		}
+	}
+}
+
+func TestPartitionerKary(t *testing.T) {
+	var tests = []struct {
+		n int
+		k int
+	}{
+		{9, 3}, {10, 3}, {27, 3}, {16, 4}, {21, 4}, {33, 4}, {20, 5},
+	}
+	for _, test := range tests {
+		reg := FakeRegistry(test.n)
+		for id := 0; id < test.n; id++ {
+			part := NewKaryPartitioner(int32(id), reg, test.k, DefaultLogger)
+			seen := map[int32]bool{int32(id): true}
+			for _, lvl := range part.Levels() {
+				ids, err := part.IdentitiesAt(lvl)
+				require.NoError(t, err)
+				require.Len(t, ids, part.Size(lvl))
+				for i, identity := range ids {
+					require.False(t, seen[identity.ID()])
+					seen[identity.ID()] = true
+					idx, err := part.IndexAtLevel(identity.ID(), lvl)
+					require.NoError(t, err)
+					require.Equal(t, i, idx)
+				}
+				if lvl > 1 {
+					// the peers of a level send the signature of their group
+					peer := NewKaryPartitioner(ids[0].ID(), reg, test.k, DefaultLogger)
+					testKaryCombine(t, reg, peer.(*karyPartitioner), part.(GroupPartitioner), int32(id), lvl)
+				}
+			}
+			require.Len(t, seen, test.n)
+		}
+	}
+}
+
+// testKaryCombine checks that the signature sent by the peer at the given
+// level is placed by the receiver in the group of the peer.
+func testKaryCombine(t *testing.T, reg Registry, peer *karyPartitioner, receiver GroupPartitioner, receiverID int32, level int) {
+	var sigs []*incomingSig
+	var expected []int32
+	for lvl := 0; lvl < level; lvl++ {
+		ids, err := peer.IdentitiesAt(lvl)
+		if err == errEmptyLevel {
+			continue
+		}
+		require.NoError(t, err)
+		bs := NewWilffBitset(len(ids))
+		bs.Set(len(ids)-1, true)
+		expected = append(expected, ids[len(ids)-1].ID())
+		sigs = append(sigs, &incomingSig{level: byte(lvl), ms: &MultiSignature{BitSet: bs, Signature: &fakeSig{true}}})
+	}
+	ms := peer.Combine(sigs, level, NewWilffBitset)
+	offset, size, err := receiver.GroupAt(int32(peer.id), level)
+	require.NoError(t, err)
+	require.Equal(t, size, ms.BitSet.BitLength())
+	require.Equal(t, len(expected), ms.BitSet.Cardinality())
+	for _, id := range expected {
+		idx, err := receiver.IndexAtLevel(id, level)
+		require.NoError(t, err)
+		require.True(t, ms.BitSet.Get(idx-offset))
+	}
+
+	full := peer.CombineFull(sigs, NewWilffBitset)
+	require.Equal(t, reg.Size(), full.BitSet.BitLength())
+	for _, id := range expected {
+		require.True(t, full.BitSet.Get(int(id)))
+	}
+}
+
+func TestStoreKaryGroups(t *testing.T) {
+	n := 16
+	reg := FakeRegistry(n)
+	part := NewKaryPartitioner(0, reg, 4, DefaultLogger)
+	store := newStore(part, NewWilffBitset, new(fakeCons))
+	// level 2 is made of the groups [4,8[, [8,12[ and [12,16[
+	groupSig := func(origin int32, bits ...int) *incomingSig {
+		bs := NewWilffBitset(12)
+		for _, b := range bits {
+			bs.Set(b, true)
+		}
+		return &incomingSig{origin: origin, level: 2, ms: newSig(bs)}
+	}
+
+	require.True(t, store.Evaluate(groupSig(4, 0, 1)) > 0)
+	store.Store(groupSig(4, 0, 1))
+	// a signature from another group merges
+	require.True(t, store.Evaluate(groupSig(9, 4, 5, 6)) > 0)
+	store.Store(groupSig(9, 4, 5, 6))
+	best, ok := store.Best(2)
+	require.True(t, ok)
+	require.Equal(t, 5, best.Cardinality())
+
+	// a better signature of the first group only replaces this group
+	require.True(t, store.Evaluate(groupSig(5, 1, 2, 3)) > 0)
+	store.Store(groupSig(5, 1, 2, 3))
+	best, _ = store.Best(2)
+	require.Equal(t, 6, best.Cardinality())
+	for _, b := range []int{1, 2, 3, 4, 5, 6} {
+		require.True(t, best.Get(b))
+	}
+	// a worse signature of the second group is useless
+	require.Equal(t, 0, store.Evaluate(groupSig(8, 4, 5)))
+
+	// completing the level gets the highest mark
+	store.Store(groupSig(12, 8, 9, 10, 11))
+	require.True(t, store.Evaluate(groupSig(4, 0, 1, 2, 3)) > 0)
+	store.Store(groupSig(4, 0, 1, 2, 3))
+	store.Store(groupSig(8, 4, 5, 6, 7))
+	best, _ = store.Best(2)
+	require.Equal(t, 12, best.Cardinality())
+	require.Equal(t, 0, store.Evaluate(groupSig(8, 4, 5, 6, 7)))
+}
+
+func TestHandelKaryPartitioner(t *testing.T) {
+	n := 33
+	config := DefaultConfig(n)
+	config.NewPartitioner = KaryPartitionerConstructor(4)
+	config.NewTimeoutStrategy = newInfiniteTimeout
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	test.Start()
+	defer test.Stop()
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(10 * time.Second):
+		t.Fatal("handel did not complete")
+	}
+}
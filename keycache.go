@@ -0,0 +1,146 @@
+package handel
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// SubtractablePublicKey is an optional interface a PublicKey can implement to
+// remove public keys from an aggregate public key. The KeyCache uses it to
+// derive the aggregate key of a bitset with few missing contributions from
+// the aggregate key of all the identities, instead of combining all the
+// contributions one by one.
+type SubtractablePublicKey interface {
+	PublicKey
+	// Subtract returns the aggregate public key without the given public key,
+	// which must be part of the aggregate.
+	Subtract(PublicKey) PublicKey
+}
+
+// KeyCache caches the aggregate public key of lists of identities, such as the
+// levels of a Handel round or a whole registry, and derives from them the
+// aggregate keys of the multi-signatures to verify. A KeyCache is safe for
+// concurrent use. It can be shared between the Handel instances of a process
+// and reused across rounds, see Config.KeyCache, as long as they all run over
+// the same registry: the cached keys are indexed by the IDs of the identities,
+// not by their public keys.
+type KeyCache struct {
+	sync.Mutex
+	// aggregate keys indexed by the encoded list of the IDs of the identities
+	keys map[string]PublicKey
+}
+
+// NewKeyCache returns an empty KeyCache.
+func NewKeyCache() *KeyCache {
+	return &KeyCache{keys: make(map[string]PublicKey)}
+}
+
+// Aggregate returns the aggregate public key of all the given identities. It
+// is computed only once for a given list of identities.
+func (k *KeyCache) Aggregate(ids []Identity, c Constructor) PublicKey {
+	key := make([]byte, 4*len(ids))
+	for i, id := range ids {
+		binary.BigEndian.PutUint32(key[4*i:], uint32(id.ID()))
+	}
+	k.Lock()
+	defer k.Unlock()
+	if full, ok := k.keys[string(key)]; ok {
+		return full
+	}
+	full := c.PublicKey()
+	for _, id := range ids {
+		full = full.Combine(id.PublicKey())
+	}
+	k.keys[string(key)] = full
+	return full
+}
+
+// VerifyMultiSignature is like the VerifyMultiSignature function but derives
+// the aggregate public key from the cached aggregate key of the registry.
+func (k *KeyCache) VerifyMultiSignature(msg []byte, ms *MultiSignature, reg Registry, cons Constructor) error {
+	if ms.BitSet.BitLength() != reg.Size() {
+		return errors.New("verify multisignature: inconsistent sizes")
+	}
+	ids, ok := reg.Identities(0, reg.Size())
+	if !ok {
+		return errors.New("verify multisignature: invalid registry")
+	}
+	aggregate := aggregateOf(k.Aggregate(ids, cons), ids, ms.BitSet, cons)
+	return aggregate.VerifySignature(msg, ms.Signature)
+}
+
+// aggregateOf returns the aggregate public key of the identities whose index
+// is set in the bitset, given the aggregate key of all of them. If more than
+// half of them are set and the public keys are subtractable, the missing ones
+// are subtracted from the full aggregate key.
+func aggregateOf(full PublicKey, ids []Identity, bs BitSet, c Constructor) PublicKey {
+	card := bs.Cardinality()
+	if card == len(ids) {
+		return full
+	}
+	aggregate := c.PublicKey()
+	if sub, ok := full.(SubtractablePublicKey); ok && 2*card > len(ids) {
+		for i := range ids {
+			if !bs.Get(i) {
+				aggregate = aggregate.Combine(ids[i].PublicKey())
+			}
+		}
+		return sub.Subtract(aggregate)
+	}
+	for i, ok := bs.NextSet(0); ok; i, ok = bs.NextSet(i + 1) {
+		aggregate = aggregate.Combine(ids[i].PublicKey())
+	}
+	return aggregate
+}
+
+// levelKeys computes the aggregate public keys of the signatures received at
+// each level of a partitioner, from the aggregate key of each level kept in a
+// KeyCache.
+type levelKeys struct {
+	sync.Mutex
+	cache *KeyCache
+	part  Partitioner
+	cons  Constructor
+	// identities and aggregate key of each level already used
+	ids  map[byte][]Identity
+	full map[byte]PublicKey
+}
+
+func newLevelKeys(cache *KeyCache, part Partitioner, c Constructor) *levelKeys {
+	if cache == nil {
+		cache = NewKeyCache()
+	}
+	return &levelKeys{
+		cache: cache,
+		part:  part,
+		cons:  c,
+		ids:   make(map[byte][]Identity),
+		full:  make(map[byte]PublicKey),
+	}
+}
+
+// aggregate returns the aggregate public key of the contributions set in the
+// bitset of the given level.
+func (l *levelKeys) aggregate(level byte, bs BitSet) (PublicKey, error) {
+	l.Lock()
+	ids, ok := l.ids[level]
+	full := l.full[level]
+	l.Unlock()
+	if !ok {
+		var err error
+		ids, err = l.part.IdentitiesAt(int(level))
+		if err != nil {
+			return nil, err
+		}
+		full = l.cache.Aggregate(ids, l.cons)
+		l.Lock()
+		l.ids[level] = ids
+		l.full[level] = full
+		l.Unlock()
+	}
+	if bs.BitLength() != len(ids) {
+		return nil, errors.New("handel: inconsistent bitset with given level")
+	}
+	return aggregateOf(full, ids, bs, l.cons), nil
+}
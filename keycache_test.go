@@ -0,0 +1,92 @@
+package handel
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sumKey is a subtractable public key: the aggregate of keys is their sum and
+// a signature is valid if it is equal to the key.
+type sumKey struct{ v int64 }
+
+func (s *sumKey) VerifySignature(msg []byte, sig Signature) error {
+	if sig.(*sumSig).v != s.v {
+		return errors.New("invalid sum signature")
+	}
+	return nil
+}
+func (s *sumKey) Combine(p PublicKey) PublicKey  { return &sumKey{s.v + p.(*sumKey).v} }
+func (s *sumKey) Subtract(p PublicKey) PublicKey { return &sumKey{s.v - p.(*sumKey).v} }
+func (s *sumKey) String() string                 { return fmt.Sprint(s.v) }
+
+type sumSig struct{ v int64 }
+
+func (s *sumSig) MarshalBinary() ([]byte, error) { return nil, nil }
+func (s *sumSig) UnmarshalBinary([]byte) error   { return nil }
+func (s *sumSig) Combine(o Signature) Signature  { return &sumSig{s.v + o.(*sumSig).v} }
+
+type sumCons struct{}
+
+func (sumCons) Signature() Signature { return new(sumSig) }
+func (sumCons) PublicKey() PublicKey { return new(sumKey) }
+
+func sumRegistry(n int) Registry {
+	ids := make([]Identity, n)
+	for i := range ids {
+		ids[i] = NewStaticIdentity(int32(i), "", &sumKey{int64(1) << uint(i)})
+	}
+	return NewArrayRegistry(ids)
+}
+
+func TestKeyCacheAggregate(t *testing.T) {
+	n := 10
+	reg := sumRegistry(n)
+	ids, _ := reg.Identities(0, n)
+	cache := NewKeyCache()
+	full := cache.Aggregate(ids, sumCons{})
+	require.Equal(t, int64(1<<uint(n)-1), full.(*sumKey).v)
+	// computed once
+	require.True(t, full == cache.Aggregate(ids, sumCons{}))
+	require.False(t, full == cache.Aggregate(ids[1:], sumCons{}))
+
+	for _, set := range [][]int{{}, {3}, {0, 9}, {0, 1, 2, 3, 4, 5}, {1, 2, 3, 4, 5, 6, 7, 8, 9}, {0, 1, 2, 3, 4, 5, 6, 7, 8, 9}} {
+		bs := NewWilffBitset(n)
+		var exp int64
+		for _, i := range set {
+			bs.Set(i, true)
+			exp += 1 << uint(i)
+		}
+		require.Equal(t, exp, aggregateOf(full, ids, bs, sumCons{}).(*sumKey).v)
+
+		ms := &MultiSignature{BitSet: bs, Signature: &sumSig{exp}}
+		require.NoError(t, cache.VerifyMultiSignature(msg, ms, reg, sumCons{}))
+		require.NoError(t, VerifyMultiSignature(msg, ms, reg, sumCons{}))
+		ms.Signature = &sumSig{exp + 1}
+		require.Error(t, cache.VerifyMultiSignature(msg, ms, reg, sumCons{}))
+	}
+}
+
+func TestLevelKeys(t *testing.T) {
+	n := 16
+	reg := sumRegistry(n)
+	part := NewBinPartitioner(0, reg, DefaultLogger)
+	keys := newLevelKeys(nil, part, sumCons{})
+
+	ids, err := part.IdentitiesAt(4)
+	require.NoError(t, err)
+	bs := NewWilffBitset(len(ids))
+	var exp int64
+	for i := 0; i < len(ids)-1; i++ {
+		bs.Set(i, true)
+		exp += 1 << uint(ids[i].ID())
+	}
+	key, err := keys.aggregate(4, bs)
+	require.NoError(t, err)
+	require.Equal(t, exp, key.(*sumKey).v)
+
+	_, err = keys.aggregate(4, NewWilffBitset(len(ids)+1))
+	require.Error(t, err)
+}
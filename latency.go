@@ -0,0 +1,222 @@
+package handel
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Latencies gives the estimated latency between the nodes of a registry. The
+// latency partitioner uses it to group nearby nodes in the lower levels.
+// Implementations must be deterministic: all nodes must see the same
+// latencies.
+type Latencies interface {
+	// Latency returns the estimated latency between the nodes at the given
+	// registry indexes.
+	Latency(i, j int) time.Duration
+}
+
+// Coordinate is the position of a node in a network coordinate system such as
+// Vivaldi: the latency between two nodes is estimated by the euclidean
+// distance between their vectors plus both their heights, in milliseconds.
+type Coordinate struct {
+	Vec    []float64
+	Height float64
+}
+
+// Coordinates are the network coordinates of each node of a registry, indexed
+// by registry index.
+type Coordinates []Coordinate
+
+// Latency implements the Latencies interface.
+func (c Coordinates) Latency(i, j int) time.Duration {
+	if i == j {
+		return 0
+	}
+	a, b := c[i], c[j]
+	var sum float64
+	for k := 0; k < len(a.Vec) && k < len(b.Vec); k++ {
+		d := a.Vec[k] - b.Vec[k]
+		sum += d * d
+	}
+	ms := math.Sqrt(sum) + a.Height + b.Height
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// LatencyMatrix is a static matrix of the latencies between each pair of
+// nodes of a registry, indexed by registry index.
+type LatencyMatrix [][]time.Duration
+
+// Latency implements the Latencies interface.
+func (l LatencyMatrix) Latency(i, j int) time.Duration {
+	return l[i][j]
+}
+
+// LoadLatencyMatrix reads a latency matrix from the given CSV file and checks
+// it covers the given registry. See ReadLatencyMatrix.
+func LoadLatencyMatrix(path string, reg Registry) (LatencyMatrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ReadLatencyMatrix(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckLatencyMatrix(m, reg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadLatencyMatrix reads a latency matrix in CSV format: the j-th field of
+// the i-th line is the latency between the nodes at registry indexes i and j,
+// in milliseconds. The matrix must be square.
+func ReadLatencyMatrix(r io.Reader) (LatencyMatrix, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	matrix := make(LatencyMatrix, len(records))
+	for i, record := range records {
+		if len(record) != len(records) {
+			return nil, fmt.Errorf("handel: latency matrix line %d has %d fields, expected %d", i, len(record), len(records))
+		}
+		matrix[i] = make([]time.Duration, len(record))
+		for j, field := range record {
+			ms, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return nil, fmt.Errorf("handel: latency matrix line %d: %s", i, err)
+			}
+			matrix[i][j] = time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	return matrix, nil
+}
+
+// NewLatencyPartitioner returns a binomial Partitioner whose levels group
+// nearby nodes: the nodes of the lower levels are the closest according to the
+// given latencies. It uses the given ID as its anchor point. All nodes must use
+// the same latencies in order to derive identical levels. It returns
+// ErrInvalidLatencies if the latencies do not cover the registry.
+func NewLatencyPartitioner(id int32, reg Registry, lat Latencies, logger Logger) (Partitioner, error) {
+	if err := CheckLatencies(lat, reg); err != nil {
+		return nil, err
+	}
+	return newPermutedPartitioner(id, reg, latencyPermutation(lat, reg.Size()), logger), nil
+}
+
+// LatencyPartitionerConstructor returns the latency partitioner constructor as
+// required for the Config, using the given latencies. It returns
+// ErrInvalidLatencies if the latencies do not cover the given registry, which
+// must be the one given to Handel.
+func LatencyPartitionerConstructor(lat Latencies, reg Registry) (func(int32, Registry, Logger) Partitioner, error) {
+	if err := CheckLatencies(lat, reg); err != nil {
+		return nil, err
+	}
+	return func(id int32, reg Registry, logger Logger) Partitioner {
+		part, err := NewLatencyPartitioner(id, reg, lat, logger)
+		if err != nil {
+			// the registry differs from the one checked
+			panic(err)
+		}
+		return part
+	}, nil
+}
+
+// ErrInvalidLatencies is returned when latencies do not cover a registry.
+var ErrInvalidLatencies = errors.New("handel: latencies do not cover the registry")
+
+// CheckLatencies returns ErrInvalidLatencies if the given latencies do not
+// cover all the nodes of the registry. Only LatencyMatrix and Coordinates
+// can be checked, other implementations are assumed to be valid.
+func CheckLatencies(lat Latencies, reg Registry) error {
+	switch l := lat.(type) {
+	case LatencyMatrix:
+		return CheckLatencyMatrix(l, reg)
+	case Coordinates:
+		if len(l) != reg.Size() {
+			return ErrInvalidLatencies
+		}
+	}
+	return nil
+}
+
+// CheckLatencyMatrix returns ErrInvalidLatencies if the matrix does not
+// contain the latencies of all the nodes of the registry.
+func CheckLatencyMatrix(m LatencyMatrix, reg Registry) error {
+	if len(m) != reg.Size() {
+		return ErrInvalidLatencies
+	}
+	for _, line := range m {
+		if len(line) != reg.Size() {
+			return ErrInvalidLatencies
+		}
+	}
+	return nil
+}
+
+// latencyPermutation returns a permutation of the n registry indexes such that
+// the binomial tree built over it groups nearby nodes. It recursively splits
+// the nodes in two halves matching the ranges of the binomial tree: the nodes
+// closest to an extremity of the set go to the first half, the others to the
+// second one. Ties are broken by registry index so that the permutation is
+// deterministic.
+func latencyPermutation(lat Latencies, n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	if n > 1 {
+		split(lat, perm, pow2(log2(n)))
+	}
+	return perm
+}
+
+// split orders the nodes filling the range of the given size of the binomial
+// tree. Only the len(nodes) first positions of the range exist.
+func split(lat Latencies, nodes []int, size int) {
+	if len(nodes) <= 1 || size <= 1 {
+		return
+	}
+	half := size / 2
+	if len(nodes) <= half {
+		// the second half of the range is empty
+		split(lat, nodes, half)
+		return
+	}
+	distance := func(i, j int) time.Duration {
+		return (lat.Latency(i, j) + lat.Latency(j, i)) / 2
+	}
+	// the extremity is the node the farthest from the lowest index
+	first := nodes[0]
+	for _, node := range nodes {
+		if node < first {
+			first = node
+		}
+	}
+	extremity := first
+	for _, node := range nodes {
+		d, e := distance(first, node), distance(first, extremity)
+		if d > e || (d == e && node < extremity) {
+			extremity = node
+		}
+	}
+	sort.Slice(nodes, func(a, b int) bool {
+		da, db := distance(extremity, nodes[a]), distance(extremity, nodes[b])
+		if da != db {
+			return da < db
+		}
+		return nodes[a] < nodes[b]
+	})
+	split(lat, nodes[:half], half)
+	split(lat, nodes[half:], half)
+}
@@ -0,0 +1,115 @@
+package handel
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLatencyMatrix(t *testing.T) {
+	m, err := ReadLatencyMatrix(strings.NewReader("0,10,2.5\n10,0,3\n2.5,3,0\n"))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(m))
+	require.Equal(t, 10*time.Millisecond, m.Latency(0, 1))
+	require.Equal(t, 2500*time.Microsecond, m.Latency(2, 0))
+	require.NoError(t, CheckLatencyMatrix(m, FakeRegistry(3)))
+	require.Equal(t, ErrInvalidLatencies, CheckLatencyMatrix(m, FakeRegistry(4)))
+
+	_, err = ReadLatencyMatrix(strings.NewReader("0,10\n10,0,3\n"))
+	require.Error(t, err)
+	_, err = ReadLatencyMatrix(strings.NewReader("0,a\n10,0\n"))
+	require.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "handel")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "latencies.csv")
+	require.NoError(t, ioutil.WriteFile(path, []byte("0,1\n1,0\n"), 0644))
+	m, err = LoadLatencyMatrix(path, FakeRegistry(2))
+	require.NoError(t, err)
+	require.Equal(t, time.Millisecond, m.Latency(1, 0))
+	_, err = LoadLatencyMatrix(path, FakeRegistry(3))
+	require.Equal(t, ErrInvalidLatencies, err)
+}
+
+func TestCoordinatesLatency(t *testing.T) {
+	c := Coordinates{
+		{Vec: []float64{0, 0}, Height: 1},
+		{Vec: []float64{3, 4}, Height: 2},
+	}
+	require.Equal(t, 8*time.Millisecond, c.Latency(0, 1))
+	require.Equal(t, time.Duration(0), c.Latency(1, 1))
+
+	require.NoError(t, CheckLatencies(c, FakeRegistry(2)))
+	_, err := NewLatencyPartitioner(0, FakeRegistry(3), c, DefaultLogger)
+	require.Equal(t, ErrInvalidLatencies, err)
+	_, err = LatencyPartitionerConstructor(c, FakeRegistry(3))
+	require.Equal(t, ErrInvalidLatencies, err)
+	_, err = LatencyPartitionerConstructor(LatencyMatrix{{0}}, FakeRegistry(2))
+	require.Equal(t, ErrInvalidLatencies, err)
+}
+
+func TestPartitionerLatencyClusters(t *testing.T) {
+	n := 16
+	clusters := 4
+	reg := FakeRegistry(n)
+	// node i is in cluster i % 4, clusters are far apart
+	coords := make(Coordinates, n)
+	for i := range coords {
+		c := float64(i % clusters)
+		coords[i] = Coordinate{Vec: []float64{c * 100, float64(i)}}
+	}
+	for i := 0; i < n; i++ {
+		part, err := NewLatencyPartitioner(int32(i), reg, coords, DefaultLogger)
+		require.NoError(t, err)
+		for _, level := range []int{1, 2} {
+			ids, err := part.IdentitiesAt(level)
+			require.NoError(t, err)
+			for _, id := range ids {
+				require.Equal(t, i%clusters, int(id.ID())%clusters)
+			}
+		}
+	}
+}
+
+func TestPartitionerLatencyConsistency(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for _, n := range []int{1, 2, 5, 13, 33} {
+		reg := FakeRegistry(n)
+		coords := make(Coordinates, n)
+		for i := range coords {
+			coords[i] = Coordinate{Vec: []float64{rnd.Float64() * 100, rnd.Float64() * 100}}
+		}
+		perm := latencyPermutation(coords, n)
+		require.Equal(t, perm, latencyPermutation(coords, n))
+
+		newPart, err := LatencyPartitionerConstructor(coords, reg)
+		require.NoError(t, err)
+		parts := make([]Partitioner, n)
+		for i := range parts {
+			parts[i] = newPart(int32(i), reg, DefaultLogger)
+		}
+		for i, part := range parts {
+			seen := map[int32]bool{int32(i): true}
+			for _, level := range part.Levels() {
+				ids, err := part.IdentitiesAt(level)
+				require.NoError(t, err)
+				for idx, id := range ids {
+					require.False(t, seen[id.ID()])
+					seen[id.ID()] = true
+					res, err := part.IndexAtLevel(id.ID(), level)
+					require.NoError(t, err)
+					require.Equal(t, idx, res)
+					testPermutedCombine(t, reg, part, parts[id.ID()], level)
+				}
+			}
+			require.Len(t, seen, n)
+		}
+	}
+}
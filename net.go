@@ -41,4 +41,8 @@ type Packet struct {
 	MultiSig []byte
 	// IndividualSig holds the individual signature of the Origin node
 	IndividualSig []byte
+	// Session identifies the Handel round this packet belongs to. It allows
+	// multiple concurrent Handel rounds to share the same Network, see
+	// SessionManager. It is empty when only one round runs on the Network.
+	Session []byte
 }
@@ -21,6 +21,7 @@ func TestCounterEncoding(t *testing.T) {
 		Origin:   156,
 		Level:    8,
 		MultiSig: []byte("History repeats itself, first as tragedy, second as farce."),
+		Session:  []byte("Brumaire"),
 	}
 
 	require.NoError(t, counter.Encode(toSend, &medium))
@@ -14,6 +14,11 @@ import (
 	"github.com/ConsenSys/handel/network"
 )
 
+// MaxPendingPackets is the maximum number of received packets waiting to be
+// dispatched to the listeners. When it is reached, the oldest pending packet
+// is dropped.
+const MaxPendingPackets = 20000
+
 // Network is a handel.Network implementation using UDP as its transport layer
 // listens on 0.0.0.0
 type Network struct {
@@ -29,6 +34,7 @@ type Network struct {
 	buff      []*handel.Packet
 	sent      int
 	rcvd      int
+	dropped   int
 }
 
 // NewNetwork creates Network baked by udp protocol
@@ -166,6 +172,12 @@ func (udpNet *Network) loop() {
 				fmt.Printf(" -- empty packet -- \n")
 				continue
 			}
+			if pendings.Len() >= MaxPendingPackets {
+				pendings.Remove(pendings.Front())
+				udpNet.Lock()
+				udpNet.dropped++
+				udpNet.Unlock()
+			}
 			pendings.PushBack(newPacket)
 			if ready {
 				send()
@@ -213,8 +225,9 @@ func (udpNet *Network) Values() map[string]float64 {
 	udpNet.RLock()
 	defer udpNet.RUnlock()
 	toSend := map[string]float64{
-		"sent": float64(udpNet.sent),
-		"rcvd": float64(udpNet.rcvd),
+		"sent":    float64(udpNet.sent),
+		"rcvd":    float64(udpNet.rcvd),
+		"dropped": float64(udpNet.dropped),
 	}
 	counter, ok := udpNet.enc.(*network.CounterEncoding)
 	if ok {
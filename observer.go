@@ -0,0 +1,66 @@
+package handel
+
+// DropReason indicates why Handel dropped a packet.
+type DropReason int
+
+const (
+	// InvalidPacket is given when the packet is inconsistent, e.g. its
+	// origin or its level do not exist.
+	InvalidPacket DropReason = iota
+	// BadReputation is given when the origin of the packet has a bad
+	// reputation. See Reputation.
+	BadReputation
+	// InvalidSignatures is given when the signatures of the packet can not
+	// be parsed or do not match the level of the packet.
+	InvalidSignatures
+)
+
+// Observer gets notified of the progress of a Handel round. It allows
+// applications to react to the protocol without parsing the logs. The
+// callbacks are called synchronously, some of them while Handel holds its
+// lock: they must return quickly and must not call any method of Handel.
+// Implementations can embed NoopObserver to only implement some of the
+// callbacks.
+type Observer interface {
+	// OnLevelStart is called when Handel starts sending its signatures to the
+	// peers of the given level, either because of the timeout strategy or
+	// because the lower levels are complete.
+	OnLevelStart(level int)
+	// OnLevelComplete is called when Handel has received a multi-signature
+	// containing the contributions of all the peers of the given level.
+	OnLevelComplete(level int)
+	// OnNewBest is called each time the best multi-signature of a level
+	// improves.
+	OnNewBest(level int, ms *MultiSignature)
+	// OnFinalSignature is called each time Handel outputs a new final
+	// multi-signature on the FinalSignatures channel.
+	OnFinalSignature(ms *MultiSignature)
+	// OnVerificationFailure is called each time a signature sent by the given
+	// origin at the given level is invalid.
+	OnVerificationFailure(origin int32, level int, err error)
+	// OnPacketDropped is called each time Handel drops an incoming packet
+	// before verifying its signatures. The error is nil for BadReputation.
+	OnPacketDropped(p *Packet, reason DropReason, err error)
+}
+
+// NoopObserver is an Observer doing nothing. It is the default Observer of
+// Handel.
+type NoopObserver struct{}
+
+// OnLevelStart implements the Observer interface.
+func (NoopObserver) OnLevelStart(level int) {}
+
+// OnLevelComplete implements the Observer interface.
+func (NoopObserver) OnLevelComplete(level int) {}
+
+// OnNewBest implements the Observer interface.
+func (NoopObserver) OnNewBest(level int, ms *MultiSignature) {}
+
+// OnFinalSignature implements the Observer interface.
+func (NoopObserver) OnFinalSignature(ms *MultiSignature) {}
+
+// OnVerificationFailure implements the Observer interface.
+func (NoopObserver) OnVerificationFailure(origin int32, level int, err error) {}
+
+// OnPacketDropped implements the Observer interface.
+func (NoopObserver) OnPacketDropped(p *Packet, reason DropReason, err error) {}
@@ -0,0 +1,115 @@
+package handel
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordObserver struct {
+	sync.Mutex
+	starts    map[int]bool
+	completes map[int]bool
+	bests     int
+	finals    int
+	failures  []int32
+	drops     []DropReason
+}
+
+func newRecordObserver() *recordObserver {
+	return &recordObserver{starts: make(map[int]bool), completes: make(map[int]bool)}
+}
+
+func (r *recordObserver) OnLevelStart(level int) {
+	r.Lock()
+	defer r.Unlock()
+	r.starts[level] = true
+}
+
+func (r *recordObserver) OnLevelComplete(level int) {
+	r.Lock()
+	defer r.Unlock()
+	r.completes[level] = true
+}
+
+func (r *recordObserver) OnNewBest(level int, ms *MultiSignature) {
+	r.Lock()
+	defer r.Unlock()
+	r.bests++
+}
+
+func (r *recordObserver) OnFinalSignature(ms *MultiSignature) {
+	r.Lock()
+	defer r.Unlock()
+	r.finals++
+}
+
+func (r *recordObserver) OnVerificationFailure(origin int32, level int, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.failures = append(r.failures, origin)
+}
+
+func (r *recordObserver) OnPacketDropped(p *Packet, reason DropReason, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.drops = append(r.drops, reason)
+}
+
+func TestObserverDrops(t *testing.T) {
+	n := 16
+	obs := newRecordObserver()
+	dropRep := func(Registry) Reputation {
+		return NewThresholdReputation(DefaultMisbehaviourThreshold, DropPacket)
+	}
+	_, handels := fakeSetupWithConfig(n, &Config{Observer: obs, NewReputation: dropRep})
+	defer CloseHandels(handels)
+	h := handels[1]
+	proc := h.proc.(*evaluatorProcessing)
+
+	h.NewPacket(&Packet{Origin: int32(n), Level: 2})
+	h.NewPacket(&Packet{Origin: 3, Level: 2, MultiSig: []byte{0x01}})
+	for i := 0; i < DefaultMisbehaviourThreshold; i++ {
+		h.Reputation().Penalize(3, MalformedPacket)
+	}
+	h.NewPacket(&Packet{Origin: 3, Level: 2, MultiSig: []byte{0x01}})
+	require.Equal(t, []DropReason{InvalidPacket, InvalidSignatures, BadReputation}, obs.drops)
+
+	inv := fullIncomingSig(2)
+	inv.origin = 2
+	inv.ms.Signature = &fakeSig{false}
+	proc.Add(inv)
+	require.False(t, proc.processStep())
+	require.Equal(t, []int32{2}, obs.failures)
+}
+
+func TestObserverRound(t *testing.T) {
+	n := 16
+	obs := newRecordObserver()
+	// levels above the first one only start because the lower levels are
+	// complete
+	conf := &Config{Observer: obs, NewTimeoutStrategy: newInfiniteTimeout}
+	_, handels := fakeSetupWithConfig(n, conf)
+	defer CloseHandels(handels)
+	for _, h := range handels {
+		h.Start()
+	}
+	for _, h := range handels {
+		select {
+		case <-h.FinalSignatures():
+		case <-time.After(5 * time.Second):
+			t.Fatal("no final signature")
+		}
+	}
+
+	obs.Lock()
+	defer obs.Unlock()
+	require.True(t, obs.finals >= n)
+	require.True(t, obs.bests >= n)
+	for _, lvl := range handels[0].Partitioner.Levels() {
+		require.True(t, obs.starts[lvl])
+	}
+	require.True(t, obs.completes[1])
+}
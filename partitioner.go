@@ -7,9 +7,10 @@ import (
 )
 
 // Partitioner is a generic interface holding the logic used to partition the
-// nodes in different buckets. The only Partitioner implemented is
-// binTreePartition using binomial tree to partition, as in the original San
-// Fermin paper.
+// nodes in different buckets. The binomialPartitioner uses a binomial tree to
+// partition, as in the original San Fermin paper. The permutedPartitioner
+// builds the same tree over a permutation of the registry, derived from a
+// shared seed or from the latencies between the nodes.
 type Partitioner interface {
 	// MaxLevel returns the maximum number of levels this partitioning strategy
 	// will use given the list of participants
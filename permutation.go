@@ -0,0 +1,128 @@
+package handel
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// permutedPartitioner is a Partitioner that builds the binomial tree of the
+// binomialPartitioner over a permutation of the registry. All bitsets are
+// expressed in the permuted order, except the ones returned by CombineFull
+// that are indexed by the registry order. All nodes must use the same
+// permutation.
+type permutedPartitioner struct {
+	*binomialPartitioner
+	// perm[i] is the registry index of the identity at position i
+	perm []int
+	// pos[i] is the position of the identity at registry index i
+	pos []int
+}
+
+// newPermutedPartitioner returns a permutedPartitioner using the given ID as
+// its anchor point and the given permutation of the registry indexes.
+func newPermutedPartitioner(id int32, reg Registry, perm []int, logger Logger) *permutedPartitioner {
+	pos := make([]int, len(perm))
+	ids := make([]Identity, len(perm))
+	for i, idx := range perm {
+		pos[idx] = i
+		ids[i], _ = reg.Identity(idx)
+	}
+	bin := NewBinPartitioner(int32(pos[id]), NewArrayRegistry(ids), logger)
+	return &permutedPartitioner{
+		binomialPartitioner: bin.(*binomialPartitioner),
+		perm:                perm,
+		pos:                 pos,
+	}
+}
+
+// NewRandomBinPartitioner returns a binomial Partitioner over the registry
+// permuted according to the given seed, using the given ID as its anchor point.
+// Consecutive IDs are thus spread among the levels, so an attacker controlling
+// a range of IDs can not concentrate its nodes in a subtree. All nodes must use
+// the same seed, e.g. a hash of the message or an epoch beacon, in order to
+// derive identical levels.
+func NewRandomBinPartitioner(id int32, reg Registry, seed []byte, logger Logger) Partitioner {
+	return newPermutedPartitioner(id, reg, seededPermutation(seed, reg.Size()), logger)
+}
+
+// RandomBinPartitionerConstructor returns the random binomial partitioner
+// constructor as required for the Config, using the given seed.
+func RandomBinPartitionerConstructor(seed []byte) func(int32, Registry, Logger) Partitioner {
+	return func(id int32, reg Registry, logger Logger) Partitioner {
+		return NewRandomBinPartitioner(id, reg, seed, logger)
+	}
+}
+
+func (r *permutedPartitioner) IndexAtLevel(globalID int32, level int) (int, error) {
+	if globalID < 0 || int(globalID) >= len(r.pos) {
+		return 0, fmt.Errorf("globalID outside registry. id=%d", globalID)
+	}
+	return r.binomialPartitioner.IndexAtLevel(int32(r.pos[globalID]), level)
+}
+
+func (r *permutedPartitioner) CombineFull(sigs []*incomingSig, nbs func(int) BitSet) *MultiSignature {
+	ms := r.binomialPartitioner.CombineFull(sigs, nbs)
+	if ms == nil {
+		return nil
+	}
+	// map the permuted positions back to the registry indexes
+	bs := nbs(ms.BitSet.BitLength())
+	for i, ok := ms.BitSet.NextSet(0); ok; i, ok = ms.BitSet.NextSet(i + 1) {
+		bs.Set(r.perm[i], true)
+	}
+	return &MultiSignature{BitSet: bs, Signature: ms.Signature}
+}
+
+// seededPermutation returns a permutation of [0, n) derived from the seed. It
+// uses a Fisher-Yates shuffle fed by SHA256 in counter mode so that the
+// permutation does not depend on the implementation of math/rand.
+func seededPermutation(seed []byte, n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	stream := &hashStream{seed: seed}
+	for i := n - 1; i > 0; i-- {
+		j := int(stream.uniform(uint64(i + 1)))
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm
+}
+
+// hashStream is a deterministic stream of random numbers derived from a seed.
+type hashStream struct {
+	seed    []byte
+	counter uint64
+	buff    []byte
+}
+
+// next returns the next 64 bits of the stream.
+func (h *hashStream) next() uint64 {
+	if len(h.buff) < 8 {
+		hash := sha256.New()
+		hash.Write(h.seed)
+		binary.Write(hash, binary.BigEndian, h.counter)
+		h.counter++
+		h.buff = hash.Sum(nil)
+	}
+	v := binary.BigEndian.Uint64(h.buff)
+	h.buff = h.buff[8:]
+	return v
+}
+
+// uniform returns a number uniformly distributed in [0, n) using rejection
+// sampling to avoid the modulo bias.
+func (h *hashStream) uniform(n uint64) uint64 {
+	if n == 0 {
+		panic(errors.New("handel: uniform over an empty range"))
+	}
+	limit := ^uint64(0) - ^uint64(0)%n
+	for {
+		v := h.next()
+		if v < limit {
+			return v % n
+		}
+	}
+}
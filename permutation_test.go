@@ -0,0 +1,127 @@
+package handel
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeededPermutation(t *testing.T) {
+	n := 100
+	perm := seededPermutation([]byte("seed"), n)
+	require.Equal(t, perm, seededPermutation([]byte("seed"), n))
+	require.NotEqual(t, perm, seededPermutation([]byte("other seed"), n))
+
+	sorted := append([]int{}, perm...)
+	sort.Ints(sorted)
+	for i := range sorted {
+		require.Equal(t, i, sorted[i])
+	}
+	require.Empty(t, seededPermutation([]byte("seed"), 0))
+}
+
+func TestPartitionerRandomBin(t *testing.T) {
+	seed := []byte("Sun is Shining...")
+	for _, n := range []int{1, 5, 13, 16, 33} {
+		reg := FakeRegistry(n)
+		parts := make([]Partitioner, n)
+		for i := range parts {
+			parts[i] = NewRandomBinPartitioner(int32(i), reg, seed, DefaultLogger)
+		}
+
+		for i, part := range parts {
+			// the level 0 is the node itself
+			ids, err := part.IdentitiesAt(0)
+			require.NoError(t, err)
+			require.Equal(t, []Identity{mustIdentity(reg, i)}, ids)
+
+			seen := map[int32]bool{int32(i): true}
+			for _, level := range part.Levels() {
+				ids, err := part.IdentitiesAt(level)
+				require.NoError(t, err)
+				require.Equal(t, part.Size(level), len(ids))
+				for idx, id := range ids {
+					require.False(t, seen[id.ID()])
+					seen[id.ID()] = true
+					res, err := part.IndexAtLevel(id.ID(), level)
+					require.NoError(t, err)
+					require.Equal(t, idx, res)
+
+					// the peer sees us at the same level, and expects the
+					// combined signature we send at the same indexes
+					peer := parts[id.ID()]
+					_, err = peer.IndexAtLevel(int32(i), level)
+					require.NoError(t, err)
+					testPermutedCombine(t, reg, part, peer, level)
+				}
+			}
+			// all levels cover the whole registry
+			require.Len(t, seen, n)
+		}
+	}
+}
+
+// testPermutedCombine checks that the signature combined by part for the
+// given level maps to the indexes expected by the peer at this level.
+func testPermutedCombine(t *testing.T, reg Registry, part, peer Partitioner, level int) {
+	var sigs []*incomingSig
+	var expected []int32
+	for lvl := 0; lvl < level; lvl++ {
+		ids, err := part.IdentitiesAt(lvl)
+		if err == errEmptyLevel {
+			continue
+		}
+		require.NoError(t, err)
+		// only the first contribution of each level is set
+		bs := NewWilffBitset(len(ids))
+		bs.Set(0, true)
+		expected = append(expected, ids[0].ID())
+		sigs = append(sigs, &incomingSig{level: byte(lvl), ms: &MultiSignature{BitSet: bs, Signature: &fakeSig{true}}})
+	}
+	ms := part.Combine(sigs, level, NewWilffBitset)
+	require.Equal(t, peer.Size(level), ms.BitSet.BitLength())
+	require.Equal(t, len(expected), ms.BitSet.Cardinality())
+	for _, id := range expected {
+		idx, err := peer.IndexAtLevel(id, level)
+		require.NoError(t, err)
+		require.True(t, ms.BitSet.Get(idx))
+	}
+
+	full := part.CombineFull(sigs, NewWilffBitset)
+	require.Equal(t, reg.Size(), full.BitSet.BitLength())
+	require.Equal(t, len(expected), full.BitSet.Cardinality())
+	for _, id := range expected {
+		require.True(t, full.BitSet.Get(int(id)))
+	}
+}
+
+func mustIdentity(reg Registry, i int) Identity {
+	id, ok := reg.Identity(i)
+	if !ok {
+		panic("no identity")
+	}
+	return id
+}
+
+func TestHandelRandomBinPartitioner(t *testing.T) {
+	n := 33
+	config := DefaultConfig(n)
+	config.NewPartitioner = RandomBinPartitionerConstructor(msg)
+	config.NewTimeoutStrategy = newInfiniteTimeout
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	test.Start()
+	defer test.Stop()
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(10 * time.Second):
+		t.Fatal("handel did not complete")
+	}
+}
@@ -7,6 +7,7 @@ package handel
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -41,6 +42,15 @@ type SigEvaluator interface {
 	Evaluate(sp *incomingSig) int
 }
 
+// VerificationListener can be implemented by a SigEvaluator that needs to know
+// the outcome of the verification of the signatures it evaluated, for example
+// to adapt its evaluation.
+type VerificationListener interface {
+	// OnVerification is called after each signature verification with the
+	// verified signature and whether it is valid or not.
+	OnVerification(sp *incomingSig, valid bool)
+}
+
 // Evaluator1 returns 1 for all signatures, leading to having all signatures
 // verified.
 type Evaluator1 struct{}
@@ -88,6 +98,22 @@ type signatureProcessing interface {
 	Verified() chan incomingSig
 }
 
+// QueuePolicy tells which signature Handel drops when its queue of signatures
+// waiting for verification is full, see Config.MaxQueueSize.
+type QueuePolicy int
+
+const (
+	// EvictLowestScore drops the signature with the lowest mark given by the
+	// evaluator strategy, the new one if its mark is lower than all the others.
+	EvictLowestScore QueuePolicy = iota
+	// EvictOldest drops the signature which has been waiting the longest.
+	EvictOldest
+	// EvictOriginQuota shares the queue equally between the origins of the
+	// signatures: it drops the oldest signature of the origin having the most
+	// signatures in the queue, the new one included.
+	EvictOriginQuota
+)
+
 // evaluator processing processing incoming signatures according to an signature
 // evaluator strategy.
 type evaluatorProcessing struct {
@@ -98,13 +124,37 @@ type evaluatorProcessing struct {
 	part Partitioner
 	cons Constructor
 	msg  []byte
+	// aggregate public keys of the signatures to verify
+	keys *levelKeys
 
 	out       chan incomingSig
 	todos     []*incomingSig
+	// signatures being verified by the workers
+	inflight []*incomingSig
+	// number of workers verifying signatures concurrently
+	workers int
+	// maximum number of signatures verified at once - only used if batcher is
+	// not nil
+	batchSize int
+	batcher   BatchVerifier
+	// maximum number of signatures in the queue - unbounded if not positive
+	maxQueue int
+	// which signature to drop when the queue is full
+	policy QueuePolicy
+	// true once the death pill has been read
+	stopped   bool
 	evaluator SigEvaluator
 	log       Logger
 	// to filter out signatures before inserting into processing queue
 	filter Filter
+	// to penalize the origin of invalid signatures - may be nil
+	rep Reputation
+	// to notify the invalid signatures
+	obs Observer
+	// to sleep and measure the verification time
+	clock Clock
+	// to notify the outcome of verifications - may be nil
+	listener VerificationListener
 
 	sigSleepTime int64
 
@@ -118,25 +168,74 @@ type evaluatorProcessing struct {
 	// Number of signatures identified as redundant by the evaluation
 	sigSuppressed int
 
+	// Number of signatures dropped because the queue was full
+	sigDropped int
+
 	// Time spent checking the signature
 	sigCheckingTime int
 }
 
-func newEvaluatorProcessing(part Partitioner, c Constructor, msg []byte, sigSleepTime int, e SigEvaluator, log Logger) signatureProcessing {
+// newEvaluatorProcessing returns a signatureProcessing verifying first the
+// signatures with the best mark given by the evaluator. If the evaluator also
+// implements the Filter or the VerificationListener interface, it is used as
+// such by the processing. If the reputation is not nil, the origins of invalid
+// signatures are penalized and the signatures from misbehaving peers are
+// deprioritized or dropped according to the reputation. The given number of
+// workers verify signatures concurrently, each one picking the best signature
+// left in the queue. If the constructor implements the BatchVerifier
+// interface and batchSize is greater than one, each worker verifies up to
+// batchSize signatures at once. If maxQueue is positive, the queue holds at
+// most maxQueue signatures, the policy telling which one is dropped when it is
+// full. The observer, if not nil, is notified of the
+// invalid signatures. The clock, RealClock if nil, is used to sleep and to
+// measure the verification time. The aggregate public keys of the levels are
+// taken from the given key cache, or from a new one if nil.
+func newEvaluatorProcessing(part Partitioner, c Constructor, keys *KeyCache, msg []byte, sigSleepTime int, workers, batchSize, maxQueue int, policy QueuePolicy, e SigEvaluator, rep Reputation, obs Observer, clock Clock, log Logger) signatureProcessing {
 	m := sync.Mutex{}
+	var filter = newIndividualSigFilter()
+	if f, ok := e.(Filter); ok {
+		filter = &combinedFilter{[]Filter{filter, f}}
+	}
+	listener, _ := e.(VerificationListener)
+	if rep != nil {
+		e = newReputationEvaluator(e, rep)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	batcher, _ := c.(BatchVerifier)
+	if batcher == nil || batchSize < 1 || sigSleepTime > 0 {
+		batchSize = 1
+	}
+	if obs == nil {
+		obs = NoopObserver{}
+	}
+	if clock == nil {
+		clock = RealClock()
+	}
 
 	ev := &evaluatorProcessing{
 		cond:         sync.NewCond(&m),
 		part:         part,
 		cons:         c,
 		msg:          msg,
+		keys:         newLevelKeys(keys, part, c),
 		sigSleepTime: int64(sigSleepTime),
 
 		out:       make(chan incomingSig, 1000),
 		todos:     make([]*incomingSig, 0),
+		workers:   workers,
+		batchSize: batchSize,
+		batcher:   batcher,
+		maxQueue:  maxQueue,
+		policy:    policy,
 		evaluator: e,
 		log:       log,
-		filter:    newIndividualSigFilter(),
+		filter:    filter,
+		rep:       rep,
+		obs:       obs,
+		clock:     clock,
+		listener:  listener,
 	}
 	return ev
 }
@@ -160,32 +259,133 @@ func (f *evaluatorProcessing) Add(sp *incomingSig) {
 	f.cond.L.Lock()
 	defer f.cond.L.Unlock()
 
-	if f.filter.Accept(sp) {
-		f.todos = append(f.todos, sp)
-		f.cond.Signal()
+	if !f.filter.Accept(sp) {
+		return
+	}
+	if f.maxQueue > 0 && len(f.todos) >= f.maxQueue && *sp != deathPillPair {
+		f.sigDropped++
+		i := f.evict(sp)
+		if i < 0 {
+			return
+		}
+		f.todos = append(f.todos[:i], f.todos[i+1:]...)
+	}
+	f.todos = append(f.todos, sp)
+	f.cond.Signal()
+}
+
+// evict returns the index in the queue of the signature to drop to make room
+// for the given one according to the policy, or -1 to drop the given one. The
+// lock must be held when calling this method.
+func (f *evaluatorProcessing) evict(sp *incomingSig) int {
+	evicted := -1
+	switch f.policy {
+	case EvictOldest:
+		for i, pair := range f.todos {
+			if *pair != deathPillPair {
+				return i
+			}
+		}
+	case EvictOriginQuota:
+		counts := make(map[int32]int)
+		counts[sp.origin]++
+		for _, pair := range f.todos {
+			counts[pair.origin]++
+		}
+		for i, pair := range f.todos {
+			if *pair == deathPillPair {
+				continue
+			}
+			if evicted < 0 || counts[pair.origin] > counts[f.todos[evicted].origin] {
+				evicted = i
+			}
+		}
+		if evicted < 0 || counts[sp.origin] > counts[f.todos[evicted].origin] {
+			return -1
+		}
+	default:
+		mark := func(pair *incomingSig) int {
+			if pair.ms == nil {
+				return 0
+			}
+			return f.evaluator.Evaluate(pair)
+		}
+		lowest := mark(sp)
+		for i, pair := range f.todos {
+			if *pair == deathPillPair {
+				continue
+			}
+			// on equal marks, the oldest signature is dropped
+			if m := mark(pair); m < lowest || evicted < 0 && m == lowest {
+				evicted, lowest = i, m
+			}
+		}
 	}
+	return evicted
 }
 
 // Look at the signatures received so far and select the one
-//  that should be processed first.
+//  that should be processed first. The selected signature is marked as being
+//  verified until verifyAndPublish is done with it.
 func (f *evaluatorProcessing) readTodos() (bool, *incomingSig) {
+	done, best := f.readBatch(1)
+	if len(best) == 0 {
+		return done, nil
+	}
+	return done, best[0]
+}
+
+// readBatch is similar to readTodos but selects up to size signatures, the
+// best ones first.
+func (f *evaluatorProcessing) readBatch(size int) (bool, []*incomingSig) {
 	f.cond.L.Lock()
 	defer f.cond.L.Unlock()
-	for len(f.todos) == 0 {
+	for {
+		for len(f.todos) == 0 && !f.stopped {
+			f.cond.Wait()
+		}
+		if f.stopped {
+			return true, nil
+		}
+		best, delayed := f.selectTodos(size)
+		if f.stopped {
+			f.cond.Broadcast()
+			return true, nil
+		}
+		if len(best) > 0 {
+			f.inflight = append(f.inflight, best...)
+			return false, best
+		}
+		if delayed == 0 {
+			return false, nil
+		}
+		// only signatures covered by the ones being verified are left, we wait
+		// for these verifications to finish before evaluating them again.
 		f.cond.Wait()
 	}
+}
 
+// selectTodos evaluates all signatures in the queue, removes the ones not
+// relevant anymore and returns up to size of the best ones. Signatures that
+// are covered by a signature currently being verified by another worker, or
+// by a signature already selected, are left in the queue without being
+// selected: their relevance depends on the outcome of that verification. It
+// returns the number of such delayed signatures. The lock must be held when
+// calling this method.
+func (f *evaluatorProcessing) selectTodos(size int) ([]*incomingSig, int) {
 	previousLen := len(f.todos)
 
 	// We need to iterate on our list. We put in
 	//   'newTodos' the signatures not selected in this round
 	//   but possibly interesting next time
 	var newTodos []*incomingSig
-	var best *incomingSig
-	bestMark := 0
+	var candidates []*incomingSig
+	var marks = make(map[*incomingSig]int)
+	var delayed int
 	for _, pair := range f.todos {
 		if *pair == deathPillPair {
-			return true, nil
+			f.stopped = true
+			return nil, 0
 		}
 		if pair.ms == nil {
 			continue
@@ -193,15 +393,26 @@ func (f *evaluatorProcessing) readTodos() (bool, *incomingSig) {
 
 		mark := f.evaluator.Evaluate(pair)
 		if mark > 0 {
-			if mark <= bestMark {
+			if covered(pair, f.inflight) {
 				newTodos = append(newTodos, pair)
-			} else {
-				if best != nil {
-					newTodos = append(newTodos, best)
-				}
-				best = pair
-				bestMark = mark
+				delayed++
+				continue
 			}
+			candidates = append(candidates, pair)
+			marks[pair] = mark
+		}
+	}
+
+	// the first signature with the greatest mark comes first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return marks[candidates[i]] > marks[candidates[j]]
+	})
+	var best []*incomingSig
+	for _, pair := range candidates {
+		if len(best) < size && !covered(pair, best) {
+			best = append(best, pair)
+		} else {
+			newTodos = append(newTodos, pair)
 		}
 	}
 
@@ -209,14 +420,27 @@ func (f *evaluatorProcessing) readTodos() (bool, *incomingSig) {
 
 	newLen := len(f.todos)
 
-	f.sigSuppressed += previousLen - newLen
-	if best != nil {
-		f.sigSuppressed-- // we don't want to count 'best' as a suppressed sig.
-		f.sigCheckedCt++
-		f.sigQueueSize += newLen
-	}
+	// we don't want to count 'best' as suppressed sigs.
+	f.sigSuppressed += previousLen - newLen - len(best)
+	f.sigCheckedCt += len(best)
+	f.sigQueueSize += newLen * len(best)
 
-	return false, best
+	return best, delayed
+}
+
+// covered returns true if the given signature is a multi-signature whose
+// contributions are all contained in one of the given multi-signatures at the
+// same level.
+func covered(sp *incomingSig, sigs []*incomingSig) bool {
+	if sp.Individual() {
+		return false
+	}
+	for _, v := range sigs {
+		if v.level == sp.level && !v.Individual() && v.ms.BitSet.IsSuperSet(sp.ms.BitSet) {
+			return true
+		}
+	}
+	return false
 }
 
 func (f *evaluatorProcessing) hasTodos() bool {
@@ -225,21 +449,33 @@ func (f *evaluatorProcessing) hasTodos() bool {
 	return len(f.todos) > 0
 }
 
+// processLoop runs the verification workers and closes the Verified channel
+// once all of them are stopped.
 func (f *evaluatorProcessing) processLoop() {
-	sigCount := 0
+	var wg sync.WaitGroup
+	for i := 0; i < f.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.workerLoop()
+		}()
+	}
+	wg.Wait()
+	close(f.out)
+}
+
+func (f *evaluatorProcessing) workerLoop() {
 	for {
 		stop := f.processStep()
 		if stop {
 			return
 		}
-		sigCount++
-		if sigCount%100 == 0 {
-			f.log.Info("processed_sig", sigCount)
-		}
 	}
 }
 
 func (f *evaluatorProcessing) Values() map[string]float64 {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
 	sigQueueSize := 0.0
 	sigCheckingTime := 0.0
 	if f.sigCheckedCt > 0 {
@@ -251,39 +487,111 @@ func (f *evaluatorProcessing) Values() map[string]float64 {
 		"sigCheckedCt":    float64(f.sigCheckedCt),
 		"sigQueueSize":    sigQueueSize,
 		"sigSuppressed":   float64(f.sigSuppressed),
+		"sigQueueDropped": float64(f.sigDropped),
 		"sigCheckingTime": sigCheckingTime,
 	}
 }
 
+// loadReporter is implemented by the signatureProcessings able to tell how
+// loaded they are.
+type loadReporter interface {
+	// load returns the number of signatures waiting for verification or being
+	// verified, and the average time taken to verify a signature.
+	load() (int, time.Duration)
+}
+
+func (f *evaluatorProcessing) load() (int, time.Duration) {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+	var verifyTime time.Duration
+	if f.sigCheckedCt > 0 {
+		verifyTime = time.Duration(f.sigCheckingTime/f.sigCheckedCt) * time.Millisecond
+	}
+	return len(f.todos) + len(f.inflight), verifyTime
+}
+
+// processStep verifies the best signatures of the queue, if any. It returns
+// true if the processing is stopped.
 func (f *evaluatorProcessing) processStep() bool {
-	done, best := f.readTodos()
+	done, best := f.readBatch(f.batchSize)
 	if done {
-		close(f.out)
 		return true
 	}
-	if best != nil {
-		f.verifyAndPublish(best)
+	switch len(best) {
+	case 0:
+	case 1:
+		f.verifyAndPublish(best[0])
+	default:
+		f.verifyBatchAndPublish(best)
 	}
 	return false
 }
 
 func (f *evaluatorProcessing) verifyAndPublish(sp *incomingSig) {
-	startTime := time.Now()
+	startTime := f.clock.Now()
 	err := (error)(nil)
 	if f.sigSleepTime <= 0 {
-		err = verifySignature(sp, f.msg, f.part, f.cons)
+		err = verifySignature(sp, f.msg, f.keys)
 	} else {
-		time.Sleep(time.Duration(f.sigSleepTime * 1000000))
+		f.clock.Sleep(time.Duration(f.sigSleepTime * 1000000))
 	}
-	endTime := time.Now()
+	f.addCheckingTime(startTime)
+	f.publish(sp, err)
+}
+
+// verifyBatchAndPublish verifies all given signatures at once with the batch
+// verifier and publishes them.
+func (f *evaluatorProcessing) verifyBatchAndPublish(sps []*incomingSig) {
+	startTime := f.clock.Now()
+	errs := verifyBatch(sps, f.msg, f.keys, f.batcher)
+	f.addCheckingTime(startTime)
+	for i, sp := range sps {
+		f.publish(sp, errs[i])
+	}
+}
 
+func (f *evaluatorProcessing) addCheckingTime(startTime time.Time) {
+	endTime := f.clock.Now()
+	f.cond.L.Lock()
 	f.sigCheckingTime += int(endTime.Sub(startTime).Nanoseconds() / 1000000)
+	checked := f.sigCheckedCt
+	f.cond.L.Unlock()
+	if checked%100 == 0 {
+		f.log.Info("processed_sig", checked)
+	}
+}
 
+// publish handles the outcome of the verification of the given signature:
+// valid signatures are sent on the Verified channel, and the origin of invalid
+// ones is penalized.
+func (f *evaluatorProcessing) publish(sp *incomingSig, err error) {
 	if err != nil {
 		f.log.Warn("verify", err)
+		if f.rep != nil {
+			if sp.Individual() {
+				f.rep.Penalize(sp.origin, InvalidIndividualSig)
+			} else {
+				f.rep.Penalize(sp.origin, InvalidMultiSig)
+			}
+		}
+		f.obs.OnVerificationFailure(sp.origin, int(sp.level), err)
 	} else {
 		f.out <- *sp
 	}
+	if f.listener != nil {
+		f.listener.OnVerification(sp, err == nil)
+	}
+
+	f.cond.L.Lock()
+	for i, v := range f.inflight {
+		if v == sp {
+			f.inflight = append(f.inflight[:i], f.inflight[i+1:]...)
+			break
+		}
+	}
+	// wake up workers waiting on signatures covered by this one
+	f.cond.Broadcast()
+	f.cond.L.Unlock()
 }
 
 // Filter holds the responsibility of filtering out the signatures before they
@@ -339,32 +647,66 @@ func (c *combinedFilter) Accept(inc *incomingSig) bool {
 // verifySignature returns true if the given signature is valid. The function
 // constructs the aggregate public key from all public keys denoted in the
 // bitset.
-func verifySignature(pair *incomingSig, msg []byte, part Partitioner, cons Constructor) error {
-	level := pair.level
-	ms := pair.ms
-	ids, err := part.IdentitiesAt(int(level))
+func verifySignature(pair *incomingSig, msg []byte, keys *levelKeys) error {
+	aggregateKey, err := keys.aggregate(pair.level, pair.ms.BitSet)
 	if err != nil {
 		return err
 	}
 
-	if ms.BitSet.BitLength() != len(ids) {
-		return errors.New("handel: inconsistent bitset with given level")
+	ms := pair.ms
+	if err := aggregateKey.VerifySignature(msg, ms.Signature); err != nil {
+		logf("processing err: from %d -> level %d -> %s", pair.origin, pair.level, ms.String())
+		return fmt.Errorf("handel: %s", err)
 	}
+	return nil
+}
 
-	// compute the aggregate public key corresponding to bitset
-	aggregateKey := cons.PublicKey()
-	for i := 0; i < ms.BitSet.BitLength(); i++ {
-		if !ms.BitSet.Get(i) {
+// verifyBatch verifies all the given signatures with the batch verifier and
+// returns the verification error of each signature, nil if valid. If the
+// batch is invalid, it is split in two halves which are verified recursively
+// until the invalid signatures are found.
+func verifyBatch(sps []*incomingSig, msg []byte, keys *levelKeys, batcher BatchVerifier) []error {
+	errs := make([]error, len(sps))
+	pubs := make([]PublicKey, len(sps))
+	var todo []int
+	for i, sp := range sps {
+		key, err := keys.aggregate(sp.level, sp.ms.BitSet)
+		if err != nil {
+			errs[i] = err
 			continue
 		}
-		aggregateKey = aggregateKey.Combine(ids[i].PublicKey())
+		pubs[i] = key
+		todo = append(todo, i)
 	}
 
-	if err := aggregateKey.VerifySignature(msg, ms.Signature); err != nil {
-		logf("processing err: from %d -> level %d -> %s", pair.origin, pair.level, ms.String())
-		return fmt.Errorf("handel: %s", err)
+	var bisect func(idx []int)
+	bisect = func(idx []int) {
+		switch len(idx) {
+		case 0:
+			return
+		case 1:
+			i := idx[0]
+			if err := pubs[i].VerifySignature(msg, sps[i].ms.Signature); err != nil {
+				logf("processing err: from %d -> level %d -> %s", sps[i].origin, sps[i].level, sps[i].ms.String())
+				errs[i] = fmt.Errorf("handel: %s", err)
+			}
+			return
+		}
+		batchPubs := make([]PublicKey, len(idx))
+		batchSigs := make([]Signature, len(idx))
+		for j, i := range idx {
+			batchPubs[j] = pubs[i]
+			batchSigs[j] = sps[i].ms.Signature
+		}
+		if batcher.VerifyBatch(msg, batchPubs, batchSigs) == nil {
+			return
+		}
+		middle := len(idx) / 2
+		bisect(idx[:middle])
+		bisect(idx[middle:])
 	}
-	return nil
+	bisect(todo)
+	return errs
 }
 
 func (is *incomingSig) String() string {
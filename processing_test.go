@@ -1,6 +1,7 @@
 package handel
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -23,7 +24,7 @@ func TestSigProcessingStrategy(t *testing.T) {
 	sig1 := fullIncomingSig(1)
 	sig2 := fullIncomingSig(2)
 
-	s := newEvaluatorProcessing(partitioner, cons, nil, 0, &EvaluatorLevel{}, nil)
+	s := newEvaluatorProcessing(partitioner, cons, nil, nil, 0, 1, 1, 0, EvictLowestScore, &EvaluatorLevel{}, nil, nil, nil, DefaultLogger)
 	ss := s.(*evaluatorProcessing)
 
 	require.Equal(t, 0, len(ss.todos))
@@ -49,6 +50,191 @@ func TestSigProcessingStrategy(t *testing.T) {
 	require.Equal(t, true, stop2)
 }
 
+func TestSigProcessingWorkers(t *testing.T) {
+	n := 16
+	registry := FakeRegistry(n)
+	partitioner := NewBinPartitioner(1, registry, DefaultLogger)
+	cons := new(fakeCons)
+	workers := 4
+	sleep := 50
+	clock := NewManualClock(time.Unix(0, 0))
+
+	s := newEvaluatorProcessing(partitioner, cons, nil, nil, sleep, workers, 1, 0, EvictLowestScore, &EvaluatorLevel{}, nil, nil, clock, DefaultLogger)
+	ss := s.(*evaluatorProcessing)
+	sigs := incomingSigs(1, 2, 3, 4, 1, 2, 3, 4)
+	for _, sig := range sigs {
+		ss.Add(sig)
+	}
+	go ss.Start()
+	for verified := 0; verified < len(sigs); verified += workers {
+		// each worker sleeps on the clock while verifying a signature
+		for clock.Pending() < workers {
+			time.Sleep(time.Millisecond)
+		}
+		ss.cond.L.Lock()
+		require.Len(t, ss.inflight, workers)
+		ss.cond.L.Unlock()
+		clock.Advance(time.Duration(sleep) * time.Millisecond)
+		for i := 0; i < workers; i++ {
+			select {
+			case <-ss.Verified():
+			case <-time.After(time.Second):
+				t.Fatal("signature not verified")
+			}
+		}
+	}
+
+	ss.Stop()
+	select {
+	case _, open := <-ss.Verified():
+		require.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("processing not stopped")
+	}
+}
+
+func TestSigProcessingCoveredByInflight(t *testing.T) {
+	n := 16
+	registry := FakeRegistry(n)
+	partitioner := NewBinPartitioner(1, registry, DefaultLogger)
+	s := newEvaluatorProcessing(partitioner, new(fakeCons), nil, nil, 0, 2, 1, 0, EvictLowestScore, &EvaluatorLevel{}, nil, nil, nil, DefaultLogger)
+	ss := s.(*evaluatorProcessing)
+
+	full := fullIncomingSig(3)
+	partial := fullIncomingSig(3)
+	partial.ms.BitSet.Set(0, false)
+	ind := fullIncomingSig(3)
+	ind.isInd = true
+
+	ss.Add(full)
+	ss.Add(partial)
+	done, best := ss.readTodos()
+	require.False(t, done)
+	require.Equal(t, full, best)
+	require.True(t, covered(partial, ss.inflight))
+	require.False(t, covered(ind, ss.inflight))
+	require.False(t, covered(fullIncomingSig(2), ss.inflight))
+
+	// the partial signature is picked once the full one is verified
+	ss.verifyAndPublish(best)
+	<-ss.Verified()
+	done, best = ss.readTodos()
+	require.False(t, done)
+	require.Equal(t, partial, best)
+}
+
+// fakeBatchCons is a fakeCons verifying signatures in batches and counting
+// the number of batches verified.
+type fakeBatchCons struct {
+	fakeCons
+	batches int
+}
+
+func (f *fakeBatchCons) VerifyBatch(msg []byte, pubs []PublicKey, sigs []Signature) error {
+	f.batches++
+	for _, sig := range sigs {
+		if !sig.(*fakeSig).verify {
+			return errors.New("invalid batch")
+		}
+	}
+	return nil
+}
+
+func TestSigProcessingBatch(t *testing.T) {
+	n := 16
+	registry := FakeRegistry(n)
+	partitioner := NewBinPartitioner(1, registry, DefaultLogger)
+	cons := new(fakeBatchCons)
+	s := newEvaluatorProcessing(partitioner, cons, nil, nil, 0, 1, 4, 0, EvictLowestScore, &EvaluatorLevel{}, nil, nil, nil, DefaultLogger)
+	ss := s.(*evaluatorProcessing)
+	require.Equal(t, 4, ss.batchSize)
+
+	sigs := incomingSigs(1, 2, 3, 4)
+	sigs[2].ms.Signature = &fakeSig{false}
+	for _, sig := range sigs {
+		ss.Add(sig)
+	}
+	require.False(t, ss.processStep())
+	require.Equal(t, 0, len(ss.todos))
+
+	verified := make(map[byte]bool)
+	for i := 0; i < 3; i++ {
+		sig := <-ss.Verified()
+		verified[sig.level] = true
+	}
+	require.Equal(t, map[byte]bool{1: true, 2: true, 4: true}, verified)
+	// one batch for all, one for each half
+	require.Equal(t, 3, cons.batches)
+	require.Equal(t, 4, ss.sigCheckedCt)
+
+	// batch size is ignored if the constructor can't verify batches
+	s = newEvaluatorProcessing(partitioner, new(fakeCons), nil, nil, 0, 1, 4, 0, EvictLowestScore, &EvaluatorLevel{}, nil, nil, nil, DefaultLogger)
+	require.Equal(t, 1, s.(*evaluatorProcessing).batchSize)
+}
+
+func TestSigProcessingQueue(t *testing.T) {
+	n := 16
+	registry := FakeRegistry(n)
+	partitioner := NewBinPartitioner(1, registry, DefaultLogger)
+	newProcessing := func(policy QueuePolicy) *evaluatorProcessing {
+		s := newEvaluatorProcessing(partitioner, new(fakeCons), nil, nil, 0, 1, 1, 3, policy, &EvaluatorLevel{}, nil, nil, nil, DefaultLogger)
+		return s.(*evaluatorProcessing)
+	}
+	// a signature of the given level from the given origin
+	sig := func(origin int32, level int) *incomingSig {
+		sp := mkIncomingSig(level)
+		sp.origin = origin
+		return sp
+	}
+	add := func(ss *evaluatorProcessing, sigs ...*incomingSig) {
+		for _, sp := range sigs {
+			ss.Add(sp)
+		}
+	}
+
+	// the lowest mark is dropped, the oldest one on equal marks
+	ss := newProcessing(EvictLowestScore)
+	s3, s1, s2, s4 := sig(0, 3), sig(0, 1), sig(0, 2), sig(0, 4)
+	add(ss, s3, s1, s2, s4)
+	require.Equal(t, sigs(s3, s2, s4), ss.todos)
+	add(ss, sig(0, 1))
+	require.Equal(t, sigs(s3, s2, s4), ss.todos)
+	s2b := sig(0, 2)
+	add(ss, s2b)
+	require.Equal(t, sigs(s3, s4, s2b), ss.todos)
+	require.Equal(t, 3.0, ss.Values()["sigQueueDropped"])
+
+	// the oldest is dropped
+	ss = newProcessing(EvictOldest)
+	add(ss, s1, s2, s3, s4)
+	require.Equal(t, sigs(s2, s3, s4), ss.todos)
+	require.Equal(t, 1.0, ss.Values()["sigQueueDropped"])
+
+	// the oldest of the origin with the most signatures is dropped
+	ss = newProcessing(EvictOriginQuota)
+	a1, a2, b, c := sig(1, 1), sig(1, 2), sig(2, 1), sig(3, 1)
+	add(ss, a1, a2, b, c)
+	require.Equal(t, sigs(a2, b, c), ss.todos)
+	a3 := sig(1, 3)
+	add(ss, a3)
+	require.Equal(t, sigs(b, c, a3), ss.todos)
+	d := sig(4, 1)
+	add(ss, d)
+	require.Equal(t, sigs(c, a3, d), ss.todos)
+	add(ss, sig(1, 4), sig(1, 4))
+	require.Equal(t, sigs(c, d, ss.todos[2]), ss.todos)
+	require.Equal(t, int32(1), ss.todos[2].origin)
+	require.Equal(t, 5.0, ss.Values()["sigQueueDropped"])
+
+	// the death pill is never dropped
+	add(ss, &deathPillPair)
+	require.Equal(t, 4, len(ss.todos))
+	add(ss, sig(5, 1))
+	require.Equal(t, 4, len(ss.todos))
+	require.Contains(t, ss.todos, &deathPillPair)
+	require.True(t, ss.processStep())
+}
+
 func TestProcessingFifo(t *testing.T) {
 	n := 16
 	registry := FakeRegistry(n)
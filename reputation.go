@@ -0,0 +1,128 @@
+package handel
+
+import (
+	"sync"
+)
+
+// Misbehaviour denotes a kind of invalid behavior Handel can observe from a
+// peer.
+type Misbehaviour int
+
+const (
+	// InvalidMultiSig is recorded when a multi-signature sent by a peer does
+	// not verify.
+	InvalidMultiSig Misbehaviour = iota
+	// InvalidIndividualSig is recorded when the individual signature of a peer
+	// does not verify.
+	InvalidIndividualSig
+	// MalformedPacket is recorded when a packet from a peer can not be parsed
+	// or is inconsistent with the level it is sent for.
+	MalformedPacket
+)
+
+// ReputationAction indicates how Handel must treat the packets of a peer.
+type ReputationAction int
+
+const (
+	// AcceptPacket lets Handel process the packets of the peer normally.
+	AcceptPacket ReputationAction = iota
+	// DeprioritizePacket lets Handel process the packets of the peer only
+	// when there is no other relevant signature to verify.
+	DeprioritizePacket
+	// DropPacket makes Handel drop the packets of the peer without even
+	// parsing them. Peers are identified by the origin of their packets, which
+	// is set by the sender: DropPacket is only safe over a transport
+	// authenticating the origin of the packets, otherwise any node can get an
+	// honest peer dropped by impersonating it.
+	DropPacket
+)
+
+// Reputation keeps track of the misbehaviours of each peer and decides how
+// Handel treats their packets. It allows Handel to not waste CPU time
+// verifying signatures from byzantine peers. Implementations must be
+// thread-safe.
+type Reputation interface {
+	// Penalize records a misbehaviour from the peer with the given ID.
+	Penalize(origin int32, m Misbehaviour)
+	// Score returns the misbehaviour score of the peer with the given ID. A
+	// score of 0 means no misbehaviour has been recorded.
+	Score(origin int32) int
+	// Action returns how Handel must treat the packets of the peer with the
+	// given ID.
+	Action(origin int32) ReputationAction
+}
+
+// DefaultMisbehaviourThreshold is the default score above which the default
+// Reputation deprioritizes the packets of a peer.
+const DefaultMisbehaviourThreshold = 10
+
+// thresholdReputation is a Reputation that counts each misbehaviour of a peer
+// and applies a given action to its packets once the count reaches a given
+// threshold.
+type thresholdReputation struct {
+	sync.Mutex
+	threshold int
+	action    ReputationAction
+	scores    map[int32]int
+}
+
+// NewThresholdReputation returns a Reputation that counts the misbehaviours
+// of each peer. Once the count of a peer reaches the threshold, the given
+// action is applied to its packets.
+func NewThresholdReputation(threshold int, action ReputationAction) Reputation {
+	return &thresholdReputation{
+		threshold: threshold,
+		action:    action,
+		scores:    make(map[int32]int),
+	}
+}
+
+func (t *thresholdReputation) Penalize(origin int32, m Misbehaviour) {
+	t.Lock()
+	defer t.Unlock()
+	t.scores[origin]++
+}
+
+func (t *thresholdReputation) Score(origin int32) int {
+	t.Lock()
+	defer t.Unlock()
+	return t.scores[origin]
+}
+
+func (t *thresholdReputation) Action(origin int32) ReputationAction {
+	t.Lock()
+	defer t.Unlock()
+	if t.scores[origin] >= t.threshold {
+		return t.action
+	}
+	return AcceptPacket
+}
+
+// reputationEvaluator is a SigEvaluator that wraps another evaluator and
+// lowers the score of signatures coming from peers with a bad reputation:
+// signatures to drop are discarded and signatures to deprioritize get the
+// lowest possible mark.
+type reputationEvaluator struct {
+	SigEvaluator
+	rep Reputation
+}
+
+func newReputationEvaluator(e SigEvaluator, rep Reputation) SigEvaluator {
+	return &reputationEvaluator{SigEvaluator: e, rep: rep}
+}
+
+// Evaluate implements the SigEvaluator interface.
+func (r *reputationEvaluator) Evaluate(sp *incomingSig) int {
+	mark := r.SigEvaluator.Evaluate(sp)
+	if mark <= 0 {
+		return mark
+	}
+	switch r.rep.Action(sp.origin) {
+	case DropPacket:
+		return 0
+	case DeprioritizePacket:
+		return 1
+	default:
+		return mark
+	}
+}
@@ -0,0 +1,84 @@
+package handel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationThreshold(t *testing.T) {
+	rep := NewThresholdReputation(2, DeprioritizePacket)
+	require.Equal(t, AcceptPacket, rep.Action(3))
+	rep.Penalize(3, InvalidMultiSig)
+	require.Equal(t, 1, rep.Score(3))
+	require.Equal(t, AcceptPacket, rep.Action(3))
+	rep.Penalize(3, MalformedPacket)
+	require.Equal(t, 2, rep.Score(3))
+	require.Equal(t, DeprioritizePacket, rep.Action(3))
+	require.Equal(t, 0, rep.Score(4))
+	require.Equal(t, AcceptPacket, rep.Action(4))
+}
+
+func TestReputationEvaluator(t *testing.T) {
+	rep := NewThresholdReputation(1, DropPacket)
+	deprio := NewThresholdReputation(1, DeprioritizePacket)
+	sig := fullIncomingSig(2)
+	sig.origin = 3
+
+	e := newReputationEvaluator(&EvaluatorLevel{}, rep)
+	require.Equal(t, 2, e.Evaluate(sig))
+	rep.Penalize(3, InvalidIndividualSig)
+	require.Equal(t, 0, e.Evaluate(sig))
+
+	e = newReputationEvaluator(&EvaluatorLevel{}, deprio)
+	deprio.Penalize(3, InvalidIndividualSig)
+	require.Equal(t, 1, e.Evaluate(sig))
+}
+
+func TestReputationHandel(t *testing.T) {
+	n := 16
+	dropRep := func(Registry) Reputation {
+		return NewThresholdReputation(DefaultMisbehaviourThreshold, DropPacket)
+	}
+	_, handels := fakeSetupWithConfig(n, &Config{NewReputation: dropRep})
+	defer CloseHandels(handels)
+	h := handels[1]
+	proc := h.proc.(*evaluatorProcessing)
+
+	// invalid signature penalizes its origin
+	inv := fullIncomingSig(2)
+	inv.origin = 3
+	inv.ms.Signature = &fakeSig{false}
+	proc.Add(inv)
+	require.False(t, proc.processStep())
+	require.Equal(t, 1, h.Reputation().Score(3))
+
+	// malformed packets penalize their origin
+	bad := &Packet{Origin: 3, Level: 2, MultiSig: []byte{0x01}}
+	h.NewPacket(bad)
+	require.Equal(t, 2, h.Reputation().Score(3))
+
+	valid := fullSig(2)
+	buff, err := valid.MarshalBinary()
+	require.NoError(t, err)
+	packet := &Packet{Origin: 3, Level: 2, MultiSig: buff}
+	h.NewPacket(packet)
+	require.Len(t, proc.todos, 1)
+	proc.todos = nil
+
+	// a blacklisted peer gets its packets dropped
+	for i := 0; i < DefaultMisbehaviourThreshold; i++ {
+		h.Reputation().Penalize(3, MalformedPacket)
+	}
+	h.NewPacket(packet)
+	require.Len(t, proc.todos, 0)
+}
+
+func TestReputationDefault(t *testing.T) {
+	rep := DefaultReputation(FakeRegistry(4))
+	for i := 0; i < DefaultMisbehaviourThreshold; i++ {
+		rep.Penalize(3, MalformedPacket)
+	}
+	// origins are not authenticated, so peers are never dropped by default
+	require.Equal(t, DeprioritizePacket, rep.Action(3))
+}
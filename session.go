@@ -0,0 +1,164 @@
+package handel
+
+import (
+	"sync"
+)
+
+// DefaultMaxPendingPackets is the default maximum number of packets the
+// SessionManager buffers for sessions that have not been registered yet.
+const DefaultMaxPendingPackets = 10000
+
+// DefaultMaxPendingPerSession is the default maximum number of packets the
+// SessionManager buffers for a single session that has not been registered
+// yet.
+const DefaultMaxPendingPerSession = 1000
+
+// maxRemovedSessions is the number of removed sessions the SessionManager
+// remembers in order to drop their late packets instead of buffering them.
+const maxRemovedSessions = 1000
+
+// SessionManager is a Listener that allows multiple Handel rounds to run
+// concurrently over the same Network. Each round is identified by a session
+// identifier that is carried by each packet. The manager dispatches incoming
+// packets to the listeners registered for the packet's session. Packets for a
+// session that has not been registered yet, for example because a peer started
+// the round before us, are buffered and delivered as soon as a listener
+// registers for that session. Packets for sessions already removed are
+// dropped.
+type SessionManager struct {
+	sync.Mutex
+	net Network
+	// listeners registered per session
+	sessions map[string][]Listener
+	// packets received for sessions not registered yet
+	pending map[string][]*Packet
+	// total number of packets buffered in pending
+	pendingCt int
+	// maximum number of packets buffered in pending
+	maxPending int
+	// maximum number of packets buffered in pending for one session
+	maxPerSession int
+	// sessions recently removed, whose packets are dropped
+	removed map[string]bool
+	// removed sessions in removal order, to forget the oldest ones
+	removedOrder []string
+}
+
+// NewSessionManager returns a SessionManager registered to the given Network.
+// It buffers at most maxPending packets for sessions that are not registered
+// yet, and at most maxPerSession for each of these sessions. If maxPending is
+// 0, DefaultMaxPendingPackets is used. If maxPerSession is 0,
+// DefaultMaxPendingPerSession is used.
+func NewSessionManager(n Network, maxPending, maxPerSession int) *SessionManager {
+	if maxPending == 0 {
+		maxPending = DefaultMaxPendingPackets
+	}
+	if maxPerSession == 0 {
+		maxPerSession = DefaultMaxPendingPerSession
+	}
+	s := &SessionManager{
+		net:           n,
+		sessions:      make(map[string][]Listener),
+		pending:       make(map[string][]*Packet),
+		maxPending:    maxPending,
+		maxPerSession: maxPerSession,
+		removed:       make(map[string]bool),
+	}
+	n.RegisterListener(s)
+	return s
+}
+
+// Network returns a Network bound to the given session. Listeners registered
+// on it only receive packets of that session and all packets sent through it
+// are stamped with that session. This is the Network to give to the Handel
+// instance running that session.
+func (s *SessionManager) Network(session []byte) Network {
+	return &sessionNetwork{
+		manager: s,
+		session: session,
+	}
+}
+
+// Remove unregisters all listeners of the given session and drops any packet
+// buffered for it. It must be called once the Handel round of this session is
+// finished. The late packets of the session are dropped afterwards; only the
+// maxRemovedSessions most recently removed sessions are remembered.
+func (s *SessionManager) Remove(session []byte) {
+	s.Lock()
+	defer s.Unlock()
+	key := string(session)
+	delete(s.sessions, key)
+	s.pendingCt -= len(s.pending[key])
+	delete(s.pending, key)
+	if s.removed[key] {
+		return
+	}
+	s.removed[key] = true
+	s.removedOrder = append(s.removedOrder, key)
+	if len(s.removedOrder) > maxRemovedSessions {
+		delete(s.removed, s.removedOrder[0])
+		s.removedOrder = s.removedOrder[1:]
+	}
+}
+
+// NewPacket implements the Listener interface. It dispatches the packet to the
+// listeners of its session or buffers it if the session is not registered
+// yet. Packets of removed sessions are dropped.
+func (s *SessionManager) NewPacket(p *Packet) {
+	s.Lock()
+	key := string(p.Session)
+	listeners, exists := s.sessions[key]
+	if !exists {
+		if !s.removed[key] && s.pendingCt < s.maxPending &&
+			len(s.pending[key]) < s.maxPerSession {
+			s.pending[key] = append(s.pending[key], p)
+			s.pendingCt++
+		}
+		s.Unlock()
+		return
+	}
+	s.Unlock()
+	for _, l := range listeners {
+		l.NewPacket(p)
+	}
+}
+
+// register adds the listener to the given session and dispatches to it all
+// packets buffered for this session.
+func (s *SessionManager) register(session []byte, l Listener) {
+	s.Lock()
+	key := string(session)
+	s.sessions[key] = append(s.sessions[key], l)
+	buffered := s.pending[key]
+	s.pendingCt -= len(buffered)
+	delete(s.pending, key)
+	s.Unlock()
+	for _, p := range buffered {
+		l.NewPacket(p)
+	}
+}
+
+// sessionNetwork is the Network view of the SessionManager for one session.
+type sessionNetwork struct {
+	manager *SessionManager
+	session []byte
+}
+
+// RegisterListener implements the Network interface
+func (n *sessionNetwork) RegisterListener(l Listener) {
+	n.manager.register(n.session, l)
+}
+
+// Send implements the Network interface
+func (n *sessionNetwork) Send(ids []Identity, p *Packet) {
+	p.Session = n.session
+	n.manager.net.Send(ids, p)
+}
+
+// Values implements the Reporter interface if the underlying Network does.
+func (n *sessionNetwork) Values() map[string]float64 {
+	if r, ok := n.manager.net.(Reporter); ok {
+		return r.Values()
+	}
+	return map[string]float64{}
+}
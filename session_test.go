@@ -0,0 +1,106 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sendNetwork struct {
+	lis  []Listener
+	sent []*Packet
+}
+
+func (s *sendNetwork) RegisterListener(l Listener) {
+	s.lis = append(s.lis, l)
+}
+
+func (s *sendNetwork) Send(ids []Identity, p *Packet) {
+	s.sent = append(s.sent, p)
+}
+
+func TestSessionManagerDispatch(t *testing.T) {
+	net := new(sendNetwork)
+	manager := NewSessionManager(net, 3, 2)
+	require.Len(t, net.lis, 1)
+
+	sessA := []byte("A")
+	sessB := []byte("B")
+	sessC := []byte("C")
+	inA := make(chan *Packet, 10)
+	inB := make(chan *Packet, 10)
+	manager.Network(sessA).RegisterListener(ChanListener(inA))
+
+	pA := &Packet{Origin: 1, Session: sessA}
+	pB := &Packet{Origin: 2, Session: sessB}
+	manager.NewPacket(pA)
+	manager.NewPacket(pB)
+	require.Equal(t, pA, <-inA)
+	require.Len(t, inA, 0)
+
+	// B is buffered until a listener registers for it
+	manager.NewPacket(&Packet{Origin: 3, Session: sessC})
+	manager.NewPacket(&Packet{Origin: 4, Session: sessC})
+	// buffer of the session is full
+	manager.NewPacket(&Packet{Origin: 5, Session: sessC})
+	require.Equal(t, 3, manager.pendingCt)
+	// buffer is full
+	manager.NewPacket(&Packet{Origin: 6, Session: []byte("D")})
+	require.Equal(t, 3, manager.pendingCt)
+	manager.Network(sessB).RegisterListener(ChanListener(inB))
+	require.Equal(t, pB, <-inB)
+	require.Equal(t, 2, manager.pendingCt)
+
+	manager.Remove(sessC)
+	require.Equal(t, 0, manager.pendingCt)
+	// late packets of removed sessions are not buffered
+	manager.NewPacket(&Packet{Origin: 3, Session: sessC})
+	manager.Remove(sessA)
+	manager.NewPacket(pA)
+	require.Len(t, inA, 0)
+	require.Equal(t, 0, manager.pendingCt)
+
+	// outgoing packets are stamped with the session
+	p := &Packet{Origin: 7}
+	manager.Network(sessB).Send(nil, p)
+	require.Equal(t, sessB, net.sent[0].Session)
+}
+
+func TestSessionManagerConcurrentHandels(t *testing.T) {
+	n := 16
+	reg := FakeRegistry(n).(*arrayRegistry)
+	ids := reg.ids
+	nets := make([]Network, n)
+	managers := make([]*SessionManager, n)
+	for i := 0; i < n; i++ {
+		nets[i] = &TestNetwork{ids[i].ID(), nets, nil}
+		managers[i] = NewSessionManager(nets[i], 0, 0)
+	}
+	cons := new(fakeCons)
+	sessions := [][]byte{[]byte("round-1"), []byte("round-2")}
+	conf := &Config{NewTimeoutStrategy: newInfiniteTimeout}
+	var handels []*Handel
+	for _, session := range sessions {
+		for i := 0; i < n; i++ {
+			net := managers[i].Network(session)
+			h := NewHandel(net, reg, ids[i], cons, msg, &fakeSig{true}, conf)
+			handels = append(handels, h)
+		}
+	}
+	defer CloseHandels(handels)
+	for _, h := range handels {
+		go h.Start()
+	}
+
+	for _, h := range handels {
+		for done := false; !done; {
+			select {
+			case ms := <-h.FinalSignatures():
+				done = ms.Cardinality() == n
+			case <-time.After(5 * time.Second):
+				t.Fatal("handel instance did not finish its session")
+			}
+		}
+	}
+}
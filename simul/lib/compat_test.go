@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/ConsenSys/handel/bls12381"
 	"github.com/ConsenSys/handel/bn256/cf"
 	"github.com/stretchr/testify/require"
 )
@@ -16,4 +17,9 @@ func TestSimulCompatible(t *testing.T) {
 	var cons Constructor
 	cons = NewSimulConstructor(bn256.NewConstructor())
 	require.NotEqual(t, "", fmt.Sprintf("%v%v%v", gen, sc, cons))
+
+	gen = bls12381.NewConstructor()
+	sc = bls12381.NewConstructor()
+	cons = NewSimulConstructor(bls12381.NewConstructor())
+	require.NotEqual(t, "", fmt.Sprintf("%v%v%v", gen, sc, cons))
 }
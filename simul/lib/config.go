@@ -12,6 +12,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/ConsenSys/handel"
+	"github.com/ConsenSys/handel/bls12381"
 	cf "github.com/ConsenSys/handel/bn256/cf"
 	golang "github.com/ConsenSys/handel/bn256/go"
 	"github.com/ConsenSys/handel/network"
@@ -45,7 +46,7 @@ type Config struct {
 	// Valid value: "udp" (default)
 	Network string
 	// which "curve system" should we use
-	// Valid value: "bn256" (default)
+	// Valid value: "bn256" (default), "bn256/cf", "bn256/go", "bls12-381"
 	Curve string
 	// which encoding should we use on the network
 	// valid value: "gob" (default)
@@ -100,10 +101,23 @@ type HandelConfig struct {
 	NodeCount int
 	// Timeout used to give to the LinearTimeout constructor
 	Timeout string
+	// Schedule is the list of the start offsets of the levels, e.g. ["0s",
+	// "50ms", "150ms"]. If not empty, it is used instead of Timeout. See
+	// handel.NewScheduleTimeout.
+	Schedule []string
+	// ScheduleFactor, if positive, starts the levels on an exponential
+	// schedule instead: the second level starts after Timeout and each
+	// following interval is ScheduleFactor times the previous one.
+	ScheduleFactor float64
 	// UnsafeSleepTimeOnSigVerify
 	UnsafeSleepTimeOnSigVerify int
+	// Number of signatures verified concurrently
+	VerifyWorkers int
+	// Maximum number of signatures verified at once in a batch
+	VerifyBatchSize int
 
 	// which queue evaluator are we choosing
+	// valid values: "store" (default), "equal" or "window"
 	Evaluator string
 }
 
@@ -207,7 +221,8 @@ func (c *Config) NewEncoding() network.Encoding {
 }
 
 // NewConstructor returns a Constructor that is using the curve denoted by the
-// curve field of the config. Valid input so far is "bn256".
+// curve field of the config. Valid inputs so far are "bn256", "bn256/cf",
+// "bn256/go" and "bls12-381".
 func (c *Config) NewConstructor() Constructor {
 	if c.Curve == "" {
 		c.Curve = "bn256/cf"
@@ -219,6 +234,8 @@ func (c *Config) NewConstructor() Constructor {
 		return &SimulConstructor{cf.NewConstructor()}
 	case "bn256/go":
 		return &SimulConstructor{golang.NewConstructor()}
+	case "bls12-381":
+		return &SimulConstructor{bls12381.NewConstructor()}
 	default:
 		panic("not implemented yet")
 	}
@@ -304,9 +321,22 @@ func (r *RunConfig) GetHandelConfig() *handel.Config {
 	ch.FastPath = r.Handel.NodeCount
 	ch.Contributions = r.GetThreshold()
 	ch.UnsafeSleepTimeOnSigVerify = r.Handel.UnsafeSleepTimeOnSigVerify
+	ch.VerifyWorkers = r.Handel.VerifyWorkers
+	ch.VerifyBatchSize = r.Handel.VerifyBatchSize
 
 	dd, err := time.ParseDuration(r.Handel.Timeout)
-	if err == nil {
+	switch {
+	case len(r.Handel.Schedule) > 0:
+		offsets := make([]time.Duration, len(r.Handel.Schedule))
+		for i, offset := range r.Handel.Schedule {
+			if offsets[i], err = time.ParseDuration(offset); err != nil {
+				panic(err)
+			}
+		}
+		ch.NewTimeoutStrategy = handel.ScheduleTimeoutConstructor(offsets)
+	case err == nil && r.Handel.ScheduleFactor > 0:
+		ch.NewTimeoutStrategy = handel.ExponentialTimeoutConstructor(dd, r.Handel.ScheduleFactor)
+	case err == nil:
 		ch.NewTimeoutStrategy = handel.LinearTimeoutConstructor(dd)
 	}
 	switch r.Handel.Evaluator {
@@ -314,6 +344,8 @@ func (r *RunConfig) GetHandelConfig() *handel.Config {
 		ch.NewEvaluatorStrategy = handel.DefaultEvaluatorStrategy
 	case "equal":
 		ch.NewEvaluatorStrategy = func(handel.SignatureStore, *handel.Handel) handel.SigEvaluator { return new(handel.Evaluator1) }
+	case "window":
+		ch.NewEvaluatorStrategy = handel.WindowEvaluatorStrategy
 	}
 	return ch
 }
@@ -114,5 +114,7 @@ func defaultStats(runConf lib.RunConfig, run int, network, period, simulation st
 		"UnsafeSleepTimeOnSigVerify": strconv.Itoa(runConf.Handel.UnsafeSleepTimeOnSigVerify),
 		"NodeCount":                  strconv.Itoa(runConf.Handel.NodeCount),
 		"timeout":                    runConf.Handel.Timeout,
+		"schedule":                   strings.Join(runConf.Handel.Schedule, ";"),
+		"scheduleFactor":             strconv.FormatFloat(runConf.Handel.ScheduleFactor, 'f', -1, 64),
 	}, nil)
 }
@@ -60,6 +60,8 @@ func main() {
 
 	// instantiate handel for all specified ids in the flags
 	var handels []*h.ReportHandel
+	// the nodes of this process run over the same registry
+	keys := h.NewKeyCache()
 	for _, id := range ids {
 		node := nodeList.Node(id)
 		network := config.NewNetwork(node.Identity)
@@ -72,6 +74,7 @@ func main() {
 		// Setup report handel and the id of the logger
 		config := runConf.GetHandelConfig()
 		config.Logger = logger
+		config.KeyCache = keys
 		handel := h.NewHandel(network, registry, node.Identity, cons.Handel(), lib.Message, signature, config)
 		reporter := h.NewReportHandel(handel)
 		handels = append(handels, reporter)
@@ -124,7 +127,7 @@ func main() {
 			processingMeasure.Record()
 			logger.Info("node", id, "sigen", "finished")
 
-			if err := h.VerifyMultiSignature(lib.Message, &sig, registry, cons.Handel()); err != nil {
+			if err := keys.VerifyMultiSignature(lib.Message, &sig, registry, cons.Handel()); err != nil {
 				panic("signature invalid !!")
 			}
 			syncer.Signal(lib.END, id)
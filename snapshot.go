@@ -0,0 +1,314 @@
+package handel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SnapshotVersion is the version of the format of the snapshots returned by
+// Handel.Snapshot. A snapshot of another version is rejected.
+const SnapshotVersion byte = 1
+
+// ErrSnapshotTruncated is returned when a snapshot ends before the state it
+// describes.
+var ErrSnapshotTruncated = errors.New("handel: truncated snapshot")
+
+// Snapshot returns the state of the round in a versioned binary format: the
+// verified signatures of the store and, for each level, the position of the
+// next peer to contact, the size of the last signature sent and whether the
+// level is completed. A process restarted in the middle of a round can resume
+// from it by setting Config.Snapshot, instead of verifying again all the
+// signatures it receives. The candidates of a store configured with
+// Config.Candidates are not saved, only the best signature of each level.
+func (h *Handel) Snapshot() ([]byte, error) {
+	h.Lock()
+	defer h.Unlock()
+	var w snapshotWriter
+	w.WriteByte(SnapshotVersion)
+	w.writeUint32(uint32(h.id.ID()))
+	w.writeUint32(uint32(h.reg.Size()))
+	w.writeBytes(h.msg)
+
+	w.writeUint32(uint32(len(h.ids)))
+	for _, id := range h.ids {
+		lvl := h.levels[id]
+		w.writeUint32(uint32(lvl.id))
+		w.writeBool(lvl.sendStarted)
+		w.writeBool(lvl.rcvCompleted)
+		w.writeUint32(uint32(lvl.sendPos))
+		w.writeUint32(uint32(lvl.sendSigSize))
+		w.writeUint32(uint32(len(lvl.nodes)))
+		for _, node := range lvl.nodes {
+			w.writeUint32(uint32(node.ID()))
+		}
+	}
+
+	s := baseStore(h.store)
+	s.Lock()
+	defer s.Unlock()
+	w.writeUint32(uint32(len(h.ids)))
+	for _, id := range h.ids {
+		level := byte(id)
+		w.WriteByte(level)
+		best, ok := s.m[level]
+		w.writeBool(ok)
+		if ok {
+			if err := w.writeSig(best); err != nil {
+				return nil, err
+			}
+		}
+		indiv := s.indivSigsVerified[level]
+		buff, err := indiv.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		w.writeBytes(buff)
+		for pos, ok := indiv.NextSet(0); ok; pos, ok = indiv.NextSet(pos + 1) {
+			if err := w.writeSig(s.individualSigs[level][pos]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return w.Bytes(), nil
+}
+
+// levelSnapshot is the state of a level read from a snapshot.
+type levelSnapshot struct {
+	id           int
+	sendStarted  bool
+	rcvCompleted bool
+	sendPos      int
+	sendSigSize  int
+	nodes        []Identity
+}
+
+// storeSnapshot is the state of the store for a level read from a snapshot.
+type storeSnapshot struct {
+	level byte
+	best  *MultiSignature
+	indiv BitSet
+	sigs  map[int]*MultiSignature
+}
+
+// restore resumes the round from the given snapshot. The snapshot is entirely
+// decoded and checked against the configuration of this Handel before
+// anything is restored, so an invalid snapshot leaves Handel unchanged.
+func (h *Handel) restore(snapshot []byte) error {
+	r := &snapshotReader{r: bytes.NewReader(snapshot)}
+	if v := r.readByte(); r.err == nil && v != SnapshotVersion {
+		return fmt.Errorf("handel: unsupported snapshot version %d", v)
+	}
+	id, size, msg := int32(r.readUint32()), int(r.readUint32()), r.readBytes()
+	if r.err != nil {
+		return r.err
+	}
+	if id != h.id.ID() || size != h.reg.Size() || !bytes.Equal(msg, h.msg) {
+		return errors.New("handel: snapshot of another round")
+	}
+
+	levels := make([]*levelSnapshot, r.readUint32())
+	if r.err != nil || len(levels) != len(h.ids) {
+		return errors.New("handel: invalid levels in snapshot")
+	}
+	for i := range levels {
+		lvl := &levelSnapshot{
+			id:           int(r.readUint32()),
+			sendStarted:  r.readBool(),
+			rcvCompleted: r.readBool(),
+			sendPos:      int(r.readUint32()),
+			sendSigSize:  int(r.readUint32()),
+		}
+		nodes := int(r.readUint32())
+		cur, ok := h.levels[lvl.id]
+		if r.err != nil {
+			return r.err
+		}
+		if !ok || lvl.id != h.ids[i] || nodes != len(cur.nodes) || lvl.sendPos > nodes {
+			return fmt.Errorf("handel: invalid level %d in snapshot", lvl.id)
+		}
+		// the peers are shuffled: their order is restored so that sendPos
+		// still points to the next peer to contact
+		ids, _ := h.Partitioner.IdentitiesAt(lvl.id)
+		seen := make(map[int]bool)
+		for j := 0; j < nodes; j++ {
+			idx, err := h.Partitioner.IndexAtLevel(int32(r.readUint32()), lvl.id)
+			if r.err != nil {
+				return r.err
+			} else if err != nil || seen[idx] {
+				return fmt.Errorf("handel: invalid peer at level %d in snapshot", lvl.id)
+			}
+			seen[idx] = true
+			lvl.nodes = append(lvl.nodes, ids[idx])
+		}
+		levels[i] = lvl
+	}
+
+	stores := make([]*storeSnapshot, r.readUint32())
+	if r.err != nil || len(stores) != len(h.ids) {
+		return errors.New("handel: invalid store in snapshot")
+	}
+	for i := range stores {
+		st := &storeSnapshot{level: r.readByte(), sigs: make(map[int]*MultiSignature)}
+		if r.err != nil {
+			return r.err
+		}
+		if int(st.level) != h.ids[i] {
+			return fmt.Errorf("handel: invalid store level %d in snapshot", st.level)
+		}
+		size := h.Partitioner.Size(int(st.level))
+		if r.readBool() {
+			st.best = r.readSig(h.cons, h.c.NewBitSet)
+			if r.err == nil && st.best.BitLength() != size {
+				return fmt.Errorf("handel: invalid signature at level %d in snapshot", st.level)
+			}
+		}
+		st.indiv = h.c.NewBitSet(size)
+		if buff := r.readBytes(); r.err == nil {
+			r.err = st.indiv.UnmarshalBinary(buff)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if st.indiv.BitLength() != size {
+			return fmt.Errorf("handel: invalid individual signatures at level %d in snapshot", st.level)
+		}
+		for pos, ok := st.indiv.NextSet(0); ok; pos, ok = st.indiv.NextSet(pos + 1) {
+			ms := r.readSig(h.cons, h.c.NewBitSet)
+			if r.err == nil && (ms.BitLength() != size || ms.Cardinality() != 1) {
+				return fmt.Errorf("handel: invalid individual signature at level %d in snapshot", st.level)
+			}
+			st.sigs[pos] = ms
+		}
+		if r.err != nil {
+			return r.err
+		}
+		stores[i] = st
+	}
+
+	for _, lvl := range levels {
+		cur := h.levels[lvl.id]
+		cur.nodes = lvl.nodes
+		cur.sendStarted = lvl.sendStarted
+		cur.rcvCompleted = lvl.rcvCompleted
+		cur.sendPos = lvl.sendPos
+		cur.sendSigSize = lvl.sendSigSize
+	}
+	s := baseStore(h.store)
+	s.Lock()
+	defer s.Unlock()
+	cs, _ := h.store.(*candidateStore)
+	for _, st := range stores {
+		s.indivSigsVerified[st.level] = st.indiv
+		s.individualSigs[st.level] = st.sigs
+		if st.best != nil {
+			s.store(st.level, st.best)
+			if cs != nil {
+				cs.candidates[st.level] = []*MultiSignature{st.best}
+			}
+		}
+	}
+	return nil
+}
+
+// baseStore returns the store underlying the given signature store.
+func baseStore(s SignatureStore) *store {
+	switch s := s.(type) {
+	case *candidateStore:
+		return s.store
+	case *store:
+		return s
+	}
+	panic("handel: unknown signature store")
+}
+
+// snapshotWriter encodes the state of a round. Its integers are written in
+// big endian and its byte slices are prefixed by their length.
+type snapshotWriter struct {
+	bytes.Buffer
+}
+
+func (w *snapshotWriter) writeUint32(v uint32) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *snapshotWriter) writeBool(v bool) {
+	if v {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+}
+
+func (w *snapshotWriter) writeBytes(b []byte) {
+	w.writeUint32(uint32(len(b)))
+	w.Write(b)
+}
+
+func (w *snapshotWriter) writeSig(ms *MultiSignature) error {
+	buff, err := ms.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	w.writeBytes(buff)
+	return nil
+}
+
+// snapshotReader decodes what a snapshotWriter encodes. It keeps the first
+// error encountered: the reads following an error return zero values.
+type snapshotReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *snapshotReader) readByte() byte {
+	if r.err != nil {
+		return 0
+	}
+	b, err := r.r.ReadByte()
+	if err != nil {
+		r.err = ErrSnapshotTruncated
+	}
+	return b
+}
+
+func (r *snapshotReader) readBool() bool {
+	return r.readByte() == 1
+}
+
+func (r *snapshotReader) readUint32() uint32 {
+	var v uint32
+	if r.err == nil && binary.Read(r.r, binary.BigEndian, &v) != nil {
+		r.err = ErrSnapshotTruncated
+	}
+	return v
+}
+
+func (r *snapshotReader) readBytes() []byte {
+	n := r.readUint32()
+	if r.err != nil {
+		return nil
+	}
+	if int64(n) > int64(r.r.Len()) {
+		r.err = ErrSnapshotTruncated
+		return nil
+	}
+	b := make([]byte, n)
+	io.ReadFull(r.r, b)
+	return b
+}
+
+func (r *snapshotReader) readSig(c Constructor, nbs func(int) BitSet) *MultiSignature {
+	buff := r.readBytes()
+	if r.err != nil {
+		return nil
+	}
+	ms := new(MultiSignature)
+	if err := ms.Unmarshal(buff, c.Signature(), nbs); err != nil {
+		r.err = err
+		return nil
+	}
+	return ms
+}
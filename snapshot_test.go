@@ -0,0 +1,89 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	n := 16
+	conf := DefaultConfig(n)
+	conf.NewTimeoutStrategy = newInfiniteTimeout
+	reg, handels := fakeSetupWithConfig(n, conf)
+	h := handels[0]
+	for _, lvl := range []int{1, 2, 3} {
+		h.store.Store(fullIncomingSig(lvl))
+	}
+	indiv := NewWilffBitset(8)
+	indiv.Set(5, true)
+	h.store.Store(&incomingSig{origin: 13, level: 4, ms: newSig(indiv), isInd: true, mappedIndex: 5})
+	h.levels[2].rcvCompleted = true
+	h.levels[3].setStarted()
+	h.levels[3].sendPos = 2
+	h.levels[3].sendSigSize = 3
+
+	snapshot, err := h.Snapshot()
+	require.NoError(t, err)
+
+	resume := func(snapshot []byte) *Handel {
+		c := *conf
+		c.Snapshot = snapshot
+		return NewHandel(h.net, reg, h.id, h.cons, msg, &fakeSig{true}, &c)
+	}
+	h2 := resume(snapshot)
+	for _, lvl := range h.ids {
+		ms, ok := h.store.Best(byte(lvl))
+		ms2, ok2 := h2.store.Best(byte(lvl))
+		require.Equal(t, ok, ok2)
+		require.Equal(t, ms, ms2)
+		require.Equal(t, h.levels[lvl], h2.levels[lvl])
+	}
+	s, s2 := baseStore(h.store), baseStore(h2.store)
+	require.Equal(t, s.indivSigsVerified, s2.indivSigsVerified)
+	require.Equal(t, s.individualSigs, s2.individualSigs)
+	// the restored signatures reach the threshold
+	h2.Start()
+	defer h2.Stop()
+	select {
+	case ms := <-h2.FinalSignatures():
+		require.Equal(t, 9, ms.Cardinality())
+	case <-time.After(time.Second):
+		t.Fatal("no final signature from the restored signatures")
+	}
+
+	// invalid snapshots are ignored
+	other := *conf
+	other.Snapshot = snapshot
+	h3 := NewHandel(h.net, reg, h.id, h.cons, []byte("another message"), &fakeSig{true}, &other)
+	for _, snap := range [][]byte{
+		snapshot[:len(snapshot)-1],
+		append([]byte{SnapshotVersion + 1}, snapshot[1:]...),
+	} {
+		h4 := resume(snap)
+		_, ok := h4.store.Best(1)
+		require.False(t, ok)
+	}
+	_, ok := h3.store.Best(1)
+	require.False(t, ok)
+}
+
+func TestSnapshotCandidates(t *testing.T) {
+	n := 16
+	conf := DefaultConfig(n)
+	conf.Candidates = DefaultCandidates
+	reg, handels := fakeSetupWithConfig(n, conf)
+	h := handels[0]
+	h.store.Store(candidateSig(0, 1, 2))
+	snapshot, err := h.Snapshot()
+	require.NoError(t, err)
+
+	c := *conf
+	c.Snapshot = snapshot
+	h2 := NewHandel(h.net, reg, h.id, h.cons, msg, &fakeSig{true}, &c)
+	// the restored best is a candidate combined with the new ones
+	best := h2.store.Store(candidateSig(4, 5))
+	require.NotNil(t, best)
+	require.Equal(t, 5, best.Cardinality())
+}
@@ -3,6 +3,7 @@ package handel
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -56,6 +57,19 @@ type store struct {
 
 	// We keep all our verified individual signatures
 	individualSigs map[byte]map[int]*MultiSignature
+
+	// the weight of each node at each level, indexed as the level's bitsets.
+	// nil if the store does not use weights.
+	weights map[byte][]int64
+	// the total weight of each level
+	levelWeights map[byte]int64
+
+	// the best multisignature of each group of each level, indexed by the
+	// offset of the group, for partitioners whose levels are made of multiple
+	// groups. The best of such a level is the combination of its groups' best.
+	groups map[byte]map[int]*MultiSignature
+	// if true, the levels made of multiple groups are handled as a whole
+	flat bool
 }
 
 // newStore is the constructor for the store.
@@ -76,9 +90,32 @@ func newStore(part Partitioner, nbs func(int) BitSet, c Constructor) *store {
 		c:                 c,
 		indivSigsVerified: indivSigsVerified,
 		individualSigs:    individualSigs,
+		groups:            make(map[byte]map[int]*MultiSignature),
 	}
 }
 
+// newWeightedStore returns a store that scores signatures according to the
+// weight they add instead of their number of contributions. It keeps the
+// signatures with the most weight at each level.
+func newWeightedStore(part Partitioner, nbs func(int) BitSet, c Constructor, reg Registry) *store {
+	s := newStore(part, nbs, c)
+	s.weights = make(map[byte][]int64)
+	s.levelWeights = make(map[byte]int64)
+	for _, lvl := range append([]int{0}, part.Levels()...) {
+		ids, err := part.IdentitiesAt(lvl)
+		if err != nil {
+			continue
+		}
+		weights := make([]int64, len(ids))
+		for i, id := range ids {
+			weights[i] = IdentityWeight(reg, id)
+			s.levelWeights[byte(lvl)] += weights[i]
+		}
+		s.weights[byte(lvl)] = weights
+	}
+	return s
+}
+
 func (r *store) Store(sp *incomingSig) *MultiSignature {
 	r.Lock()
 	defer r.Unlock()
@@ -93,11 +130,74 @@ func (r *store) Store(sp *incomingSig) *MultiSignature {
 
 	n, store := r.unsafeCheckMerge(sp)
 	if store {
+		if group := r.groupOf(sp); group >= 0 {
+			if r.groups[sp.level] == nil {
+				r.groups[sp.level] = make(map[int]*MultiSignature)
+			}
+			r.groups[sp.level][group] = n
+			n = r.combineGroups(sp.level)
+		}
 		r.store(sp.level, n)
 	}
 	return n
 }
 
+// groupOf returns the offset of the group of the origin of the signature if
+// the level of the signature is made of multiple groups, -1 otherwise. See
+// GroupPartitioner.
+func (r *store) groupOf(sp *incomingSig) int {
+	gp, ok := r.part.(GroupPartitioner)
+	if !ok || r.flat || sp.level == 0 {
+		return -1
+	}
+	offset, size, err := gp.GroupAt(sp.origin, int(sp.level))
+	if err != nil || size == r.part.Size(int(sp.level)) {
+		return -1
+	}
+	return offset
+}
+
+// bestOf returns the best multisignature and the verified individual
+// signatures the given signature competes with: the ones of its level, or
+// only the ones of its group if the level is made of multiple groups.
+func (r *store) bestOf(sp *incomingSig) (*MultiSignature, BitSet) {
+	indiv := r.indivSigsVerified[sp.level]
+	group := r.groupOf(sp)
+	if group < 0 {
+		return r.m[sp.level], indiv
+	}
+	gp := r.part.(GroupPartitioner)
+	_, size, _ := gp.GroupAt(sp.origin, int(sp.level))
+	mask := r.nbs(indiv.BitLength())
+	for i := group; i < group+size; i++ {
+		mask.Set(i, true)
+	}
+	return r.groups[sp.level][group], indiv.And(mask)
+}
+
+// combineGroups returns the combination of the best multisignatures of all
+// the groups of the level.
+func (r *store) combineGroups(level byte) *MultiSignature {
+	offsets := make([]int, 0, len(r.groups[level]))
+	for offset := range r.groups[level] {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	var combined *MultiSignature
+	for _, offset := range offsets {
+		ms := r.groups[level][offset]
+		if combined == nil {
+			combined = &MultiSignature{BitSet: ms.BitSet.Clone(), Signature: ms.Signature}
+			continue
+		}
+		combined = &MultiSignature{
+			BitSet:    combined.BitSet.Or(ms.BitSet),
+			Signature: combined.Signature.Combine(ms.Signature),
+		}
+	}
+	return combined
+}
+
 func (r *store) Evaluate(sp *incomingSig) int {
 	r.Lock()
 	defer r.Unlock()
@@ -111,12 +211,23 @@ func (r *store) Evaluate(sp *incomingSig) int {
 func (r *store) unsafeEvaluate(sp *incomingSig) int {
 	toReceive := r.part.Size(int(sp.level))
 	// The best signature we have for this level, may be nil
-	curBestMs := r.m[sp.level]
+	levelBestMs := r.m[sp.level]
 
-	if curBestMs != nil && toReceive == curBestMs.Cardinality() {
+	if levelBestMs != nil && toReceive == levelBestMs.Cardinality() {
 		// Completed level, we won't need this signature
 		return 0
 	}
+	// The best signature this one competes with, may be nil. It is the best
+	// of the level or of the group of the signature.
+	curBestMs, indivVerified := r.bestOf(sp)
+	// The number of sigs of the level outside of the group of the signature
+	others := 0
+	if levelBestMs != nil {
+		others = levelBestMs.Cardinality()
+		if curBestMs != nil {
+			others -= curBestMs.Cardinality()
+		}
+	}
 
 	if sp.Individual() && r.indivSigsVerified[sp.level].Get(int(sp.mappedIndex)) {
 		// We have already verified this individual signature
@@ -131,7 +242,7 @@ func (r *store) unsafeEvaluate(sp *incomingSig) int {
 
 	// We take into account the individual signatures already verified we could
 	// add.
-	withIndiv := sp.ms.BitSet.Or(r.indivSigsVerified[sp.level])
+	withIndiv := sp.ms.BitSet.Or(indivVerified)
 	// The number of signatures in our new best
 	newTotal := 0
 	// The number of sigs we add with our new best compared to the existing one.
@@ -140,6 +251,8 @@ func (r *store) unsafeEvaluate(sp *incomingSig) int {
 	// The number of sigs in our new best that come from combining it with
 	// individual sigs
 	combineCt := 0
+	// The contributions of our new best
+	newSet := withIndiv
 	if curBestMs == nil {
 		// the best is the new multi-sig combined with the ind. sigs
 		newTotal = withIndiv.Cardinality()
@@ -159,9 +272,15 @@ func (r *store) unsafeEvaluate(sp *incomingSig) int {
 			newTotal = finalSet.Cardinality()
 			addedSigs = newTotal - curBestMs.BitSet.Cardinality()
 			combineCt = finalSet.Xor(curBestMs.BitSet.Or(sp.ms.BitSet)).Cardinality()
+			newSet = finalSet
 		}
 	}
 
+	if r.weights != nil {
+		// We favor the signatures adding the most weight
+		addedSigs = r.addedWeight(sp.level, newSet, curBestMs, addedSigs)
+	}
+
 	if addedSigs <= 0 {
 		// It doesn't add any value, we keep only the individual signatures for
 		//  byzantine fault tolerance scenario but we can remove the others.
@@ -171,7 +290,7 @@ func (r *store) unsafeEvaluate(sp *incomingSig) int {
 		return 0
 	}
 
-	if newTotal == toReceive {
+	if newTotal+others == toReceive {
 		// This completes a level! That's the best options for us. We give a
 		// greater value to the first levels/
 		return 1000000 - int(sp.level)*10 - combineCt
@@ -186,7 +305,8 @@ func (r *store) unsafeEvaluate(sp *incomingSig) int {
 // previously verified signatures) and a boolean: true if the signature should
 // replace the previous one, false if the signature should be discarded
 func (r *store) unsafeCheckMerge(sp *incomingSig) (*MultiSignature, bool) {
-	ms2 := r.m[sp.level] // The best signature we have for this level, may be nil
+	// The best signature we have for this level or group, may be nil
+	ms2, vl := r.bestOf(sp)
 	if ms2 == nil {
 		// If we don't have a best for this level it means we haven't verified
 		// an individual sig yet; so we can return now without checking the
@@ -201,14 +321,17 @@ func (r *store) unsafeCheckMerge(sp *incomingSig) (*MultiSignature, bool) {
 		best.Signature = ms2.Signature.Combine(sp.ms.Signature)
 	}
 
-	vl := r.indivSigsVerified[sp.level]
 	iS := best.And(vl).Xor(vl)
 	// in iS, all bits set mean that we can complement our current best with the
 	// corresponding individual sig.
 	// There are some individual sigs that we could use.
 	// Let's check first that the final signature will be larger than the
 	// existing one
-	if iS.Cardinality()+best.Cardinality() <= ms2.Cardinality() {
+	if r.weights != nil {
+		if !r.heavier(sp.level, best.BitSet.Or(iS), ms2.BitSet) {
+			return nil, false
+		}
+	} else if iS.Cardinality()+best.Cardinality() <= ms2.Cardinality() {
 		return nil, false
 	}
 
@@ -228,6 +351,48 @@ func (r *store) unsafeCheckMerge(sp *incomingSig) (*MultiSignature, bool) {
 	return best, true
 }
 
+// weight returns the summed weight of the contributions set in the bitset at
+// the given level.
+func (r *store) weight(level byte, bs BitSet) int64 {
+	weights := r.weights[level]
+	var total int64
+	for i, ok := bs.NextSet(0); ok && i < len(weights); i, ok = bs.NextSet(i + 1) {
+		total += weights[i]
+	}
+	return total
+}
+
+// heavier returns true if the contributions of bs1 weigh more than those of
+// bs2, or weigh the same but are more numerous.
+func (r *store) heavier(level byte, bs1, bs2 BitSet) bool {
+	w1, w2 := r.weight(level, bs1), r.weight(level, bs2)
+	if w1 != w2 {
+		return w1 > w2
+	}
+	return bs1.Cardinality() > bs2.Cardinality()
+}
+
+// addedWeight returns the weight added by the given contributions compared to
+// the current best of the level, expressed in number of contributions of the
+// average weight of the level so marks stay in the same ranges as in the
+// unweighted mode. If no weight is added, the contributions only add value if
+// they are more numerous, i.e. if addedSigs is positive.
+func (r *store) addedWeight(level byte, newSet BitSet, curBest *MultiSignature, addedSigs int) int {
+	added := r.weight(level, newSet)
+	if curBest != nil {
+		added -= r.weight(level, curBest.BitSet)
+	}
+	total := r.levelWeights[level]
+	if added < 0 {
+		return 0
+	}
+	if added == 0 || total <= 0 {
+		return min(addedSigs, 1)
+	}
+	scaled := int(float64(added) / float64(total) * float64(r.part.Size(int(level))))
+	return max(scaled, 1)
+}
+
 func (r *store) Best(level byte) (*MultiSignature, bool) {
 	r.Lock()
 	defer r.Unlock()
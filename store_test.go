@@ -195,3 +195,53 @@ func TestStoreReplace(t *testing.T) {
 		//require.Equal(t, test.highest, store.Highest())
 	}
 }
+
+func TestStoreWeighted(t *testing.T) {
+	n := 8
+	weights := []int64{1, 1, 1, 1, 10, 1, 1, 1}
+	reg := &fakeWeightedRegistry{FakeRegistry(n), weights}
+	part := NewBinPartitioner(0, reg, DefaultLogger)
+	store := newWeightedStore(part, NewWilffBitset, new(fakeCons), reg)
+	unweighted := newStore(part, NewWilffBitset, new(fakeCons))
+
+	// level 3 contains the nodes 4 to 7
+	mkSig := func(bits ...int) *incomingSig {
+		bs := NewWilffBitset(4)
+		for _, b := range bits {
+			bs.Set(b, true)
+		}
+		return &incomingSig{
+			origin: 5,
+			level:  3,
+			ms:     &MultiSignature{BitSet: bs, Signature: &fakeSig{true}},
+		}
+	}
+
+	// the heaviest contribution is preferred over the most numerous one
+	heavy := mkSig(0)
+	light := mkSig(1, 2)
+	require.True(t, store.Evaluate(heavy) > store.Evaluate(light))
+	require.True(t, unweighted.Evaluate(heavy) < unweighted.Evaluate(light))
+
+	many := mkSig(1, 2, 3)
+	store.Store(many)
+	unweighted.Store(many)
+
+	// a signature with less contributions but more weight replaces the best
+	replace := mkSig(0, 1)
+	require.Equal(t, 0, unweighted.Evaluate(replace))
+	require.True(t, store.Evaluate(replace) > 0)
+	ms := store.Store(replace)
+	require.Equal(t, replace.ms, ms)
+	best, ok := store.Best(3)
+	require.True(t, ok)
+	require.Equal(t, replace.ms, best)
+
+	// a lighter signature is not worth verifying anymore
+	require.Equal(t, 0, store.Evaluate(mkSig(1, 2, 3)))
+	ms = store.Store(mkSig(1, 2))
+	require.Nil(t, ms)
+	// but a disjoint one is merged
+	ms = store.Store(mkSig(2, 3))
+	require.Equal(t, 4, ms.Cardinality())
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/rand"
 	"fmt"
 	mathRand "math/rand"
-	"time"
 
 	lvl "github.com/go-kit/kit/log/level"
 )
@@ -13,9 +12,11 @@ import (
 // implementations on Handel.
 // DO NOT USE IT IN PRODUCTION.
 type Test struct {
-	reg     Registry
-	cons    Constructor
-	msg     []byte
+	reg  Registry
+	cons Constructor
+	msg  []byte
+	// aggregate keys shared by all the handel instances
+	keys    *KeyCache
 	nets    []Network
 	handels []*Handel
 	// notifies when one handel instance have finished
@@ -51,19 +52,26 @@ func NewTest(keys []SecretKey, pubs []PublicKey, c Constructor, msg []byte, conf
 	}
 	reg := NewArrayRegistry(ids)
 	logger := NewKitLogger(lvl.AllowDebug())
+	cache := NewKeyCache()
 	for i := 0; i < n; i++ {
 		newPartitioner := func(id int32, reg Registry, logger Logger) Partitioner {
 			return NewBinPartitioner(id, reg, logger)
 		}
 		conf := *config
 		conf.Logger = logger
-		conf.NewPartitioner = newPartitioner
+		if conf.NewPartitioner == nil {
+			conf.NewPartitioner = newPartitioner
+		}
+		if conf.KeyCache == nil {
+			conf.KeyCache = cache
+		}
 		handels[i] = NewHandel(nets[i], reg, ids[i], c, msg, sigs[i], &conf)
 	}
 	return &Test{
 		reg:             reg,
 		cons:            c,
 		msg:             msg,
+		keys:            cache,
 		nets:            nets,
 		handels:         handels,
 		done:            make(chan bool),
@@ -125,7 +133,6 @@ func (t *Test) isOffline(nodeID int32) bool {
 // Stop manually every handel instances
 func (t *Test) Stop() {
 	close(t.done)
-	time.Sleep(30 * time.Millisecond)
 	for _, handel := range t.handels {
 		if t.isOffline(handel.id.ID()) {
 			continue
@@ -197,18 +204,22 @@ func isIncluded(i int, all []int) bool {
 
 // waitFinalSig loops over the final signatures output by a specific handel
 // instance until the signature is complete. In that case, it notifies the main
-// watch routine.
+// watch routine. It returns when the channel of final signatures is closed,
+// e.g. by the completion policy.
 func (t *Test) waitFinalSig(i int) {
 	h := t.handels[i]
 	ch := h.FinalSignatures()
 	for {
 		select {
-		case ms := <-ch:
+		case ms, ok := <-ch:
+			if !ok {
+				return
+			}
 			/*fmt.Println("+++++++ t.reg ", t.reg)*/
 			//fmt.Println("+++++++ ms", ms)
 			/*fmt.Println("+++++++ ms.BitSet ", ms.BitSet)*/
 			if ms.BitSet.Cardinality() >= t.threshold {
-				if err := VerifyMultiSignature(t.msg, &ms, t.reg, t.cons); err != nil {
+				if err := t.keys.VerifyMultiSignature(t.msg, &ms, t.reg, t.cons); err != nil {
 					fmt.Println(" !!! --- Test verification failed --- !!!")
 				}
 				// one full !
@@ -8,6 +8,8 @@ import (
 // TimeoutStrategy decides when to start a level in Handel. A basic strategy
 // starts level according to a linear timeout function: level $i$ starts at time
 // $i * period$. The interface is started and stopped by the Handel main logic.
+// Strategies implementing ProgressListener are also notified of the progress
+// of the levels.
 type TimeoutStrategy interface {
 	// Called by handel when it starts
 	Start()
@@ -21,7 +23,8 @@ type linearTimeout struct {
 	newLevel func(int)
 	levels   []int
 	period   time.Duration
-	ticker   *time.Ticker
+	clock    Clock
+	ticker   Ticker
 	done     chan bool
 	started  bool
 }
@@ -50,6 +53,7 @@ func LinearTimeoutConstructor(period time.Duration) func(h *Handel, levels []int
 func NewLinearTimeout(h *Handel, levels []int, period time.Duration) TimeoutStrategy {
 	return &linearTimeout{
 		period:   period,
+		clock:    h.c.Clock,
 		newLevel: h.StartLevel,
 		levels:   levels,
 		done:     make(chan bool, 1),
@@ -60,8 +64,8 @@ func (l *linearTimeout) Start() {
 	l.Lock()
 	defer l.Unlock()
 	l.started = true
-	l.ticker = time.NewTicker(l.period)
-	go l.linearLevels(l.ticker.C)
+	l.ticker = l.clock.NewTicker(l.period)
+	go l.linearLevels(l.ticker.Chan())
 }
 
 func (l *linearTimeout) Stop() {
@@ -70,6 +74,7 @@ func (l *linearTimeout) Stop() {
 	if !l.started {
 		return
 	}
+	l.started = false
 	l.ticker.Stop()
 	close(l.done)
 }
@@ -86,3 +91,286 @@ func (l *linearTimeout) linearLevels(c <-chan time.Time) {
 		}
 	}
 }
+
+// scheduleTimeout starts each level at a fixed offset from the start of
+// Handel.
+type scheduleTimeout struct {
+	sync.Mutex
+	newLevel func(int)
+	levels   []int
+	offsets  []time.Duration
+	clock    Clock
+	timers   []Timer
+	started  bool
+}
+
+// NewScheduleTimeout returns a TimeoutStrategy that starts the i-th level of
+// the given levels at the i-th given offset from the start of Handel. If there
+// are fewer offsets than levels, the remaining levels are started at the
+// interval between the two last offsets, or the last offset if there is only
+// one.
+func NewScheduleTimeout(h *Handel, levels []int, offsets []time.Duration) TimeoutStrategy {
+	return &scheduleTimeout{
+		clock:    h.c.Clock,
+		newLevel: h.StartLevel,
+		levels:   levels,
+		offsets:  extendSchedule(offsets, len(levels)),
+	}
+}
+
+// ScheduleTimeoutConstructor returns the schedule timeout constructor as
+// required for the Config.
+func ScheduleTimeoutConstructor(offsets []time.Duration) func(h *Handel, levels []int) TimeoutStrategy {
+	return func(h *Handel, levels []int) TimeoutStrategy {
+		return NewScheduleTimeout(h, levels, offsets)
+	}
+}
+
+// ExponentialTimeoutConstructor returns the constructor as required for the
+// Config of the schedule timeout following the ExponentialSchedule with the
+// given period and factor.
+func ExponentialTimeoutConstructor(period time.Duration, factor float64) func(h *Handel, levels []int) TimeoutStrategy {
+	return func(h *Handel, levels []int) TimeoutStrategy {
+		return NewScheduleTimeout(h, levels, ExponentialSchedule(period, factor, len(levels)))
+	}
+}
+
+// ExponentialSchedule returns the offsets of a schedule for the given number
+// of levels where the first level starts right away, the second one after the
+// given period, and each following interval is factor times the previous one.
+func ExponentialSchedule(period time.Duration, factor float64, levels int) []time.Duration {
+	offsets := make([]time.Duration, levels)
+	interval := float64(period)
+	for i := 1; i < levels; i++ {
+		offsets[i] = offsets[i-1] + time.Duration(interval)
+		interval *= factor
+	}
+	return offsets
+}
+
+// extendSchedule returns the given offsets extended to the given number of
+// levels.
+func extendSchedule(offsets []time.Duration, levels int) []time.Duration {
+	if len(offsets) == 0 || len(offsets) >= levels {
+		return offsets
+	}
+	interval := offsets[len(offsets)-1]
+	if len(offsets) > 1 {
+		interval -= offsets[len(offsets)-2]
+	}
+	extended := append([]time.Duration{}, offsets...)
+	for len(extended) < levels {
+		extended = append(extended, extended[len(extended)-1]+interval)
+	}
+	return extended
+}
+
+func (s *scheduleTimeout) Start() {
+	s.Lock()
+	s.started = true
+	var now []int
+	for i, level := range s.levels {
+		if i >= len(s.offsets) {
+			break
+		}
+		if s.offsets[i] <= 0 {
+			now = append(now, level)
+			continue
+		}
+		level := level
+		s.timers = append(s.timers, s.clock.AfterFunc(s.offsets[i], func() {
+			s.start(level)
+		}))
+	}
+	s.Unlock()
+	for _, level := range now {
+		s.newLevel(level)
+	}
+}
+
+func (s *scheduleTimeout) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	s.started = false
+	for _, t := range s.timers {
+		t.Stop()
+	}
+}
+
+// start starts the given level unless the strategy is stopped.
+func (s *scheduleTimeout) start(level int) {
+	s.Lock()
+	started := s.started
+	s.Unlock()
+	if started {
+		s.newLevel(level)
+	}
+}
+
+// LevelProgress describes the progress of a level of a Handel round. Handel
+// feeds it to the TimeoutStrategies implementing ProgressListener.
+type LevelProgress struct {
+	// Level is the level whose best multi-signature improved.
+	Level int
+	// Received is the number of contributions of the best multi-signature of
+	// the level.
+	Received int
+	// Size is the number of peers of the level.
+	Size int
+	// Pending is the number of signatures waiting for verification.
+	Pending int
+	// VerifyTime is the average time taken to verify a signature.
+	VerifyTime time.Duration
+}
+
+// ProgressListener is implemented by the TimeoutStrategies adapting to the
+// progress of the round. Handel calls OnLevelProgress each time the best
+// multi-signature of a level improves, without holding its lock: the strategy
+// can start levels from within the call.
+type ProgressListener interface {
+	OnLevelProgress(p LevelProgress)
+}
+
+// DefaultProgressFraction is the default fraction of the contributions of the
+// current level after which the adaptive timeout strategy starts the next one.
+const DefaultProgressFraction = 0.75
+
+// adaptiveTimeoutMaxStretch is the maximum number of periods the adaptive
+// timeout strategy waits for a level when verification is the bottleneck.
+const adaptiveTimeoutMaxStretch = 4
+
+// adaptiveTimeout starts each level at the latest one period after the
+// previous one, as linearTimeout, but adapts to the progress of the round: it
+// starts the next level as soon as a fraction of the contributions of the
+// current level is received, and it stretches the period while signatures are
+// waiting for verification.
+type adaptiveTimeout struct {
+	sync.Mutex
+	newLevel func(int)
+	levels   []int
+	period   time.Duration
+	fraction float64
+	clock    Clock
+	timer    Timer
+	// index of the next level to start
+	next int
+	// time waited for the current level
+	waited time.Duration
+	// verification backlog of the last progress
+	backlog time.Duration
+	started bool
+}
+
+// NewAdaptiveTimeout returns a TimeoutStrategy that starts each level at the
+// latest one period after the previous one. It starts the next level earlier
+// once the given fraction of the contributions of the current level has been
+// received, and waits up to adaptiveTimeoutMaxStretch periods while
+// signatures are waiting for verification.
+func NewAdaptiveTimeout(h *Handel, levels []int, period time.Duration, fraction float64) TimeoutStrategy {
+	return &adaptiveTimeout{
+		period:   period,
+		fraction: fraction,
+		clock:    h.c.Clock,
+		newLevel: h.StartLevel,
+		levels:   levels,
+	}
+}
+
+// AdaptiveTimeoutConstructor returns the adaptive timeout constructor as
+// required for the Config.
+func AdaptiveTimeoutConstructor(period time.Duration, fraction float64) func(h *Handel, levels []int) TimeoutStrategy {
+	return func(h *Handel, levels []int) TimeoutStrategy {
+		return NewAdaptiveTimeout(h, levels, period, fraction)
+	}
+}
+
+func (a *adaptiveTimeout) Start() {
+	a.Lock()
+	a.started = true
+	level, ok := a.unsafeNextLevel()
+	a.Unlock()
+	if ok {
+		a.newLevel(level)
+	}
+}
+
+func (a *adaptiveTimeout) Stop() {
+	a.Lock()
+	defer a.Unlock()
+	a.started = false
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+}
+
+// OnLevelProgress implements the ProgressListener interface.
+func (a *adaptiveTimeout) OnLevelProgress(p LevelProgress) {
+	a.Lock()
+	a.backlog = time.Duration(p.Pending) * p.VerifyTime
+	if !a.started || a.next == 0 || a.levels[a.next-1] != p.Level {
+		// only the progress of the current level matters
+		a.Unlock()
+		return
+	}
+	if float64(p.Received) < a.fraction*float64(p.Size) {
+		a.Unlock()
+		return
+	}
+	level, ok := a.unsafeNextLevel()
+	a.Unlock()
+	if ok {
+		a.newLevel(level)
+	}
+}
+
+// fire is called once the current level has waited for its period. The next
+// level is started unless signatures are waiting for verification. The given
+// index is the one of the next level when the call was scheduled, so that
+// outdated calls are ignored.
+func (a *adaptiveTimeout) fire(next int) {
+	a.Lock()
+	if !a.started || next != a.next {
+		a.Unlock()
+		return
+	}
+	if a.backlog > 0 && a.waited < adaptiveTimeoutMaxStretch*a.period {
+		wait := a.backlog
+		if max := adaptiveTimeoutMaxStretch*a.period - a.waited; wait > max {
+			wait = max
+		}
+		a.waited += wait
+		a.backlog = 0
+		a.unsafeSchedule(wait)
+		a.Unlock()
+		return
+	}
+	level, ok := a.unsafeNextLevel()
+	a.Unlock()
+	if ok {
+		a.newLevel(level)
+	}
+}
+
+// unsafeNextLevel returns the next level to start, if any, and schedules the
+// start of the following one.
+func (a *adaptiveTimeout) unsafeNextLevel() (int, bool) {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	if a.next >= len(a.levels) {
+		return 0, false
+	}
+	level := a.levels[a.next]
+	a.next++
+	a.waited = a.period
+	if a.next < len(a.levels) {
+		a.unsafeSchedule(a.period)
+	}
+	return level, true
+}
+
+// unsafeSchedule calls fire after the given duration for the next level.
+func (a *adaptiveTimeout) unsafeSchedule(d time.Duration) {
+	next := a.next
+	a.timer = a.clock.AfterFunc(d, func() { a.fire(next) })
+}
@@ -46,3 +46,95 @@ func TestTimeoutLinear(t *testing.T) {
 	// -1 because we increment even after the last one
 	require.Equal(t, levels, level-1)
 }
+
+func TestTimeoutAdaptive(t *testing.T) {
+	n := 16
+	clock := NewManualClock(time.Unix(0, 0))
+	_, handels := fakeSetupWithConfig(n, &Config{Clock: clock})
+	defer CloseHandels(handels)
+	levels := []int{1, 2, 3, 4}
+	period := 20 * time.Millisecond
+	adaptive := NewAdaptiveTimeout(handels[0], levels, period, 0.5).(*adaptiveTimeout)
+	started := make(chan int, len(levels))
+	adaptive.newLevel = func(level int) {
+		started <- level
+	}
+	adaptive.Start()
+	defer adaptive.Stop()
+	require.Equal(t, 1, <-started)
+
+	// the current level is complete: the next one starts right away
+	adaptive.OnLevelProgress(LevelProgress{Level: 1, Received: 1, Size: 1})
+	require.Equal(t, 2, <-started)
+	// the progress of other levels does not matter
+	adaptive.OnLevelProgress(LevelProgress{Level: 1, Received: 1, Size: 1})
+	require.Len(t, started, 0)
+
+	// not enough progress: the level starts after the period
+	adaptive.OnLevelProgress(LevelProgress{Level: 2, Received: 0, Size: 2})
+	clock.Advance(period - time.Millisecond)
+	require.Len(t, started, 0)
+	clock.Advance(time.Millisecond)
+	require.Equal(t, 3, <-started)
+
+	// signatures waiting for verification stretch the period
+	adaptive.OnLevelProgress(LevelProgress{Level: 3, Received: 1, Size: 4, Pending: 3, VerifyTime: 10 * time.Millisecond})
+	clock.Advance(period)
+	require.Len(t, started, 0)
+	clock.Advance(30*time.Millisecond - time.Millisecond)
+	require.Len(t, started, 0)
+	clock.Advance(time.Millisecond)
+	require.Equal(t, 4, <-started)
+	require.Equal(t, 0, clock.Pending())
+}
+
+func TestHandelAdaptiveTimeout(t *testing.T) {
+	n := 32
+	config := DefaultConfig(n)
+	config.NewTimeoutStrategy = AdaptiveTimeoutConstructor(DefaultLevelTimeout, DefaultProgressFraction)
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	test.SetOfflineNodes(3, 17)
+	test.SetThreshold(n - 2)
+	test.Start()
+	defer test.Stop()
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(10 * time.Second):
+		t.Fatal("handel did not complete")
+	}
+}
+
+func TestTimeoutSchedule(t *testing.T) {
+	ms := time.Millisecond
+	require.Equal(t, []time.Duration{0, 10 * ms, 30 * ms, 70 * ms}, ExponentialSchedule(10*ms, 2, 4))
+	require.Equal(t, []time.Duration{0, 10 * ms, 25 * ms, 40 * ms}, extendSchedule([]time.Duration{0, 10 * ms, 25 * ms}, 4))
+	require.Equal(t, []time.Duration{5 * ms, 10 * ms}, extendSchedule([]time.Duration{5 * ms}, 2))
+
+	n := 16
+	clock := NewManualClock(time.Unix(0, 0))
+	_, handels := fakeSetupWithConfig(n, &Config{Clock: clock})
+	defer CloseHandels(handels)
+	levels := []int{1, 2, 3, 4}
+	schedule := NewScheduleTimeout(handels[0], levels, []time.Duration{0, 10 * ms, 15 * ms}).(*scheduleTimeout)
+	started := make(chan int, len(levels))
+	schedule.newLevel = func(level int) {
+		started <- level
+	}
+	schedule.Start()
+	require.Equal(t, 1, <-started)
+	clock.Advance(10 * ms)
+	require.Equal(t, 2, <-started)
+	clock.Advance(5 * ms)
+	require.Equal(t, 3, <-started)
+	clock.Advance(4 * ms)
+	require.Len(t, started, 0)
+	schedule.Stop()
+	clock.Advance(ms)
+	require.Len(t, started, 0)
+}
@@ -177,6 +177,10 @@ func sigs(sigs ...*incomingSig) []*incomingSig {
 }
 
 func FakeSetup(n int) (Registry, []*Handel) {
+	return fakeSetupWithConfig(n, &Config{})
+}
+
+func fakeSetupWithConfig(n int, conf *Config) (Registry, []*Handel) {
 	reg := FakeRegistry(n).(*arrayRegistry)
 	ids := reg.ids
 	nets := make([]Network, n)
@@ -188,7 +192,7 @@ func FakeSetup(n int) (Registry, []*Handel) {
 	newPartitioner := func(id int32, reg Registry, logger Logger) Partitioner {
 		return NewBinPartitioner(id, reg, DefaultLogger)
 	}
-	conf := &Config{NewPartitioner: newPartitioner}
+	conf.NewPartitioner = newPartitioner
 	for i := 0; i < n; i++ {
 		handels[i] = NewHandel(nets[i], reg, ids[i], cons, msg, &fakeSig{true}, conf)
 	}
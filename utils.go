@@ -17,6 +17,13 @@ func min(x, y int) int {
 	return y
 }
 
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
+
 func pow2(n int) int {
 	return int(math.Pow(2, float64(n)))
 }
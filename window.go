@@ -0,0 +1,140 @@
+package handel
+
+import (
+	"sync"
+)
+
+// DefaultWindowSize is the initial size of the verification window used by
+// the window evaluator.
+const DefaultWindowSize = 16
+
+// DefaultMinWindowSize is the minimum size of the verification window used
+// by the window evaluator.
+const DefaultMinWindowSize = 1
+
+// DefaultMaxWindowSize is the maximum size of the verification window used
+// by the window evaluator.
+const DefaultMaxWindowSize = 128
+
+// windowEvaluator implements the windowing technique of the Handel paper to
+// resist denial of service attacks. Each node ranks the peers of each level
+// using the order in which it contacts them, i.e. the shuffled list of nodes
+// of each level. At each level, only the signatures coming from peers whose
+// rank is inside a window are evaluated by the store. The window starts at the
+// lowest ranked peer from which no valid signature has been verified yet. Its
+// size is doubled each time a verification succeeds and divided by four each
+// time a verification fails. Signatures outside of the window are kept with
+// the lowest possible mark so they are only verified when there is nothing
+// better to do.
+//
+// windowEvaluator is also a Filter that rejects signatures sent by peers that
+// do not belong to the level of the signature, and a VerificationListener to
+// move and adapt its windows.
+type windowEvaluator struct {
+	sync.Mutex
+	store SignatureStore
+	// the rank of each peer at each level
+	ranks map[byte]map[int32]int
+	// the peers at each level ordered by rank
+	ordered map[byte][]int32
+	// the peers of each level from which a valid signature has been verified
+	verified map[byte]map[int32]bool
+	// the lowest rank at each level whose signature is not verified yet
+	cursor map[byte]int
+	// the current window size at each level
+	window map[byte]int
+	min    int
+	max    int
+}
+
+// NewWindowEvaluator returns a SigEvaluator that only evaluates signatures
+// from peers inside a window over the ranking of the peers of each level. The
+// ranking is the order of the nodes of each level in h. Signatures inside the
+// window are evaluated by the store. The window size starts at initial and
+// varies between min and max depending on the verification outcomes.
+func NewWindowEvaluator(store SignatureStore, h *Handel, initial, min, max int) SigEvaluator {
+	w := &windowEvaluator{
+		store:    store,
+		ranks:    make(map[byte]map[int32]int),
+		ordered:  make(map[byte][]int32),
+		verified: make(map[byte]map[int32]bool),
+		cursor:   make(map[byte]int),
+		window:   make(map[byte]int),
+		min:      min,
+		max:      max,
+	}
+	for id, lvl := range h.levels {
+		level := byte(id)
+		w.ranks[level] = make(map[int32]int)
+		w.verified[level] = make(map[int32]bool)
+		w.window[level] = initial
+		for rank, node := range lvl.nodes {
+			w.ranks[level][node.ID()] = rank
+			w.ordered[level] = append(w.ordered[level], node.ID())
+		}
+	}
+	return w
+}
+
+// WindowEvaluatorConstructor returns the window evaluator constructor as
+// required for the Config.
+func WindowEvaluatorConstructor(initial, min, max int) func(SignatureStore, *Handel) SigEvaluator {
+	return func(s SignatureStore, h *Handel) SigEvaluator {
+		return NewWindowEvaluator(s, h, initial, min, max)
+	}
+}
+
+// WindowEvaluatorStrategy returns a window evaluator using the default window
+// sizes. See NewWindowEvaluator.
+func WindowEvaluatorStrategy(s SignatureStore, h *Handel) SigEvaluator {
+	return NewWindowEvaluator(s, h, DefaultWindowSize, DefaultMinWindowSize, DefaultMaxWindowSize)
+}
+
+// Evaluate implements the SigEvaluator interface.
+func (w *windowEvaluator) Evaluate(sp *incomingSig) int {
+	mark := w.store.Evaluate(sp)
+	if mark <= 0 || sp.level == 0 {
+		return mark
+	}
+	w.Lock()
+	defer w.Unlock()
+	rank, exists := w.ranks[sp.level][sp.origin]
+	if !exists {
+		return 0
+	}
+	if rank < w.cursor[sp.level]+w.window[sp.level] {
+		return mark
+	}
+	return 1
+}
+
+// Accept implements the Filter interface.
+func (w *windowEvaluator) Accept(sp *incomingSig) bool {
+	if sp.level == 0 {
+		return true
+	}
+	w.Lock()
+	defer w.Unlock()
+	_, exists := w.ranks[sp.level][sp.origin]
+	return exists
+}
+
+// OnVerification implements the VerificationListener interface.
+func (w *windowEvaluator) OnVerification(sp *incomingSig, valid bool) {
+	w.Lock()
+	defer w.Unlock()
+	level := sp.level
+	if _, exists := w.ranks[level][sp.origin]; !exists {
+		return
+	}
+	if !valid {
+		w.window[level] = max(w.window[level]/4, w.min)
+		return
+	}
+	w.window[level] = min(w.window[level]*2, w.max)
+	w.verified[level][sp.origin] = true
+	ordered := w.ordered[level]
+	for w.cursor[level] < len(ordered) && w.verified[level][ordered[w.cursor[level]]] {
+		w.cursor[level]++
+	}
+}
@@ -0,0 +1,72 @@
+package handel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowEvaluator(t *testing.T) {
+	n := 16
+	_, handels := FakeSetup(n)
+	h := handels[1]
+	lvl := h.levels[4]
+	// level 4 of node 1 has 8 nodes
+	require.Len(t, lvl.nodes, 8)
+
+	w := NewWindowEvaluator(newStore(h.Partitioner, NewWilffBitset, new(fakeCons)), h, 2, 1, 4).(*windowEvaluator)
+	sigFrom := func(rank int) *incomingSig {
+		s := fullIncomingSig(4)
+		s.origin = lvl.nodes[rank].ID()
+		return s
+	}
+	inWindow := w.Evaluate(sigFrom(1))
+	require.True(t, inWindow > 1)
+	require.Equal(t, 1, w.Evaluate(sigFrom(2)))
+
+	// peer from another level is rejected
+	other := fullIncomingSig(4)
+	other.origin = 0
+	require.False(t, w.Accept(other))
+	require.Equal(t, 0, w.Evaluate(other))
+	require.True(t, w.Accept(sigFrom(7)))
+
+	// verification of rank 0 moves the window and doubles its size
+	w.OnVerification(sigFrom(0), true)
+	require.Equal(t, 1, w.cursor[4])
+	require.Equal(t, 4, w.window[4])
+	require.Equal(t, inWindow, w.Evaluate(sigFrom(4)))
+	require.Equal(t, 1, w.Evaluate(sigFrom(5)))
+	// window size is capped
+	w.OnVerification(sigFrom(3), true)
+	require.Equal(t, 4, w.window[4])
+	require.Equal(t, 1, w.cursor[4])
+
+	// failure shrinks the window
+	w.OnVerification(sigFrom(1), false)
+	require.Equal(t, 1, w.window[4])
+	require.Equal(t, inWindow, w.Evaluate(sigFrom(1)))
+	require.Equal(t, 1, w.Evaluate(sigFrom(2)))
+}
+
+func TestWindowEvaluatorHandel(t *testing.T) {
+	n := 33
+	config := DefaultConfig(n)
+	config.NewTimeoutStrategy = newInfiniteTimeout
+	config.NewEvaluatorStrategy = WindowEvaluatorConstructor(1, 1, 8)
+	secrets := make([]SecretKey, n)
+	pubs := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		secrets[i] = new(fakeSecret)
+		pubs[i] = &fakePublic{true}
+	}
+	test := NewTest(secrets, pubs, new(fakeCons), msg, config)
+	test.Start()
+	defer test.Stop()
+	select {
+	case <-test.WaitCompleteSuccess():
+	case <-time.After(10 * time.Second):
+		t.Fatal("handel with window evaluator did not finish")
+	}
+}